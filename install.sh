@@ -5,6 +5,8 @@ set -euo pipefail
 SERVICE_NAME="sub-mon"
 INSTALL_BIN="/usr/local/bin/sub-mon"
 INSTALL_SERVICE="/etc/systemd/system/sub-mon.service"
+INSTALL_COLLECT_SERVICE="/etc/systemd/system/sub-mon-collect.service"
+INSTALL_COLLECT_TIMER="/etc/systemd/system/sub-mon-collect.timer"
 INSTALL_CONFIG_DIR="/etc/sub-mon"
 INSTALL_CONFIG="${INSTALL_CONFIG_DIR}/config.yaml"
 SERVICE_USER="sub-mon"
@@ -14,6 +16,8 @@ SERVICE_WORKDIR="/var/lib/sub-mon"
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 SOURCE_BIN="${1:-${SCRIPT_DIR}/bin/sub-mon}"
 SOURCE_SERVICE="${SCRIPT_DIR}/sub-mon.service"
+SOURCE_COLLECT_SERVICE="${SCRIPT_DIR}/sub-mon-collect.service"
+SOURCE_COLLECT_TIMER="${SCRIPT_DIR}/sub-mon-collect.timer"
 SOURCE_CONFIG_EXAMPLE="${SCRIPT_DIR}/config.example.yaml"
 
 require_root() {
@@ -30,10 +34,12 @@ check_sources() {
     exit 1
   fi
 
-  if [[ ! -f "${SOURCE_SERVICE}" ]]; then
-    echo "Service file not found: ${SOURCE_SERVICE}" >&2
-    exit 1
-  fi
+  for unit in "${SOURCE_SERVICE}" "${SOURCE_COLLECT_SERVICE}" "${SOURCE_COLLECT_TIMER}"; do
+    if [[ ! -f "${unit}" ]]; then
+      echo "Service file not found: ${unit}" >&2
+      exit 1
+    fi
+  done
 }
 
 ensure_service_account() {
@@ -58,6 +64,9 @@ install_binary() {
 
 install_service_file() {
   install -D -m 0644 "${SOURCE_SERVICE}" "${INSTALL_SERVICE}"
+  # The collector is an alternative to the service and is not enabled here.
+  install -D -m 0644 "${SOURCE_COLLECT_SERVICE}" "${INSTALL_COLLECT_SERVICE}"
+  install -D -m 0644 "${SOURCE_COLLECT_TIMER}" "${INSTALL_COLLECT_TIMER}"
 }
 
 install_config_if_needed() {
@@ -126,6 +135,7 @@ main() {
   echo "Installation complete."
   echo "- Binary : ${INSTALL_BIN}"
   echo "- Service: ${INSTALL_SERVICE}"
+  echo "- Timer  : ${INSTALL_COLLECT_TIMER} (not enabled)"
   echo "- Config : ${INSTALL_CONFIG}"
 }
 
@@ -3,12 +3,13 @@ package kimi
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httputil"
 	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
 )
 
 var (
@@ -105,7 +106,7 @@ func (c *Client) doRequest(ctx context.Context, method, urlStr string, body []by
 	c.logResponse(resp, respBody)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, provider.NewHTTPError(resp)
 	}
 
 	return respBody, nil
@@ -123,7 +124,7 @@ func (c *Client) GetUsages(ctx context.Context) (*UsagesResponse, error) {
 	}
 
 	var result UsagesResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := provider.DecodeJSON(body, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -138,7 +139,7 @@ func (c *Client) GetSubscription(ctx context.Context) (*SubscriptionResponse, er
 	}
 
 	var result SubscriptionResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := provider.DecodeJSON(body, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -24,6 +24,11 @@ func (a *Adapter) DisplayName() string {
 	return "Kimi Code"
 }
 
+// UsageRequests is the number of upstream requests per FetchUsage: usages and subscription.
+func (a *Adapter) UsageRequests() int {
+	return 2
+}
+
 func (a *Adapter) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		SupportsUsageMetrics:  true,
@@ -70,12 +75,13 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 	}
 
 	if usagesErr != nil {
-		return nil, fmt.Errorf("failed to fetch kimi usages: %v", usagesErr)
+		return nil, fmt.Errorf("failed to fetch kimi usages: %w", usagesErr)
 	}
 
 	if subErr != nil {
 		snap.Status = provider.StatusError
 		snap.Error = fmt.Sprintf("failed to fetch subscription: %v", subErr)
+		snap.ErrorCode = provider.ErrorCodeOf(subErr)
 	} else if subResp != nil {
 		snap.Plan = &provider.PlanInfo{
 			Name: subResp.Subscription.Goods.Title,
@@ -99,7 +105,9 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 			Window: provider.UsageWindow{
 				ID:       "daily",
 				Label:    "Daily",
+				StartsAt: provider.Ptr(usage.Detail.ResetTime.Add(-24 * time.Hour)),
 				ResetsAt: &usage.Detail.ResetTime,
+				Duration: 24 * time.Hour,
 			},
 			Amount: provider.UsageAmount{
 				Used:      provider.Ptr(float64(used)),
@@ -110,12 +118,16 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 		})
 
 		for _, limitInfo := range usage.Limits {
-			duration := limitInfo.Window.Duration
-			timeUnit := normalizeTimeUnit(string(limitInfo.Window.TimeUnit))
+			duration := windowDuration(limitInfo.Window.Duration, limitInfo.Window.TimeUnit)
+			nameLabel := metricLabel(limitInfo.Window.Duration, limitInfo.Window.TimeUnit)
+			windowLabel := provider.WindowLabel(duration)
+			if windowLabel == "" {
+				windowLabel = nameLabel
+			}
 
-			windowLabel := fmt.Sprintf("%d %s", duration, timeUnit)
-			if timeUnit == "TIME_UNIT_MINUTE" {
-				windowLabel = fmt.Sprintf("%dm", duration)
+			var startsAt *time.Time
+			if duration > 0 {
+				startsAt = provider.Ptr(limitInfo.Detail.ResetTime.Add(-duration))
 			}
 
 			windowLimit, _ := strconv.Atoi(string(limitInfo.Detail.Limit))
@@ -123,11 +135,13 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 			windowRemaining, _ := strconv.Atoi(string(limitInfo.Detail.Remaining))
 
 			metrics = append(metrics, provider.UsageMetric{
-				Name: fmt.Sprintf("Window (%s)", windowLabel),
+				Name: fmt.Sprintf("Window (%s)", nameLabel),
 				Window: provider.UsageWindow{
 					ID:       "window",
 					Label:    windowLabel,
+					StartsAt: startsAt,
 					ResetsAt: &limitInfo.Detail.ResetTime,
+					Duration: duration,
 				},
 				Amount: provider.UsageAmount{
 					Used:      provider.Ptr(float64(windowUsed)),
@@ -155,12 +169,33 @@ func isCodingScope(scope FlexibleString) bool {
 	return err == nil
 }
 
-func normalizeTimeUnit(unit string) string {
-	u := strings.TrimSpace(unit)
-	switch u {
-	case "5":
-		return "TIME_UNIT_MINUTE"
+// metricLabel is the window part of the metric name, e.g. "300m". It
+// predates the window label and must not change, history, cycles and alert
+// rules are keyed on the metric name.
+func metricLabel(duration int, unit FlexibleString) string {
+	u := strings.TrimSpace(string(unit))
+	if u == "TIME_UNIT_MINUTE" || u == "5" {
+		return fmt.Sprintf("%dm", duration)
+	}
+	return fmt.Sprintf("%d %s", duration, u)
+}
+
+// windowDuration converts Kimi's duration and timeUnit into a duration, or 0
+// for an unknown unit. The unit is either the enum name or its number, the
+// API has been seen returning "5" for minutes.
+func windowDuration(duration int, unit FlexibleString) time.Duration {
+	var base time.Duration
+	switch strings.TrimSpace(string(unit)) {
+	case "TIME_UNIT_SECOND":
+		base = time.Second
+	case "TIME_UNIT_MINUTE", "5":
+		base = time.Minute
+	case "TIME_UNIT_HOUR":
+		base = time.Hour
+	case "TIME_UNIT_DAY":
+		base = 24 * time.Hour
 	default:
-		return u
+		return 0
 	}
+	return time.Duration(duration) * base
 }
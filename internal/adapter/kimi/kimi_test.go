@@ -3,6 +3,7 @@ package kimi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -89,6 +90,26 @@ func TestClient_GetUsages(t *testing.T) {
 	t.Logf("Window used: %s/%s", usage.Limits[0].Detail.Used, usage.Limits[0].Detail.Limit)
 }
 
+func TestClient_GetUsages_Unauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthenticated"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("expired-token", "expired-cookie")
+	client.baseURL = server.URL
+	client.Debug = false
+
+	_, err := client.GetUsages(context.Background())
+	if !errors.Is(err, provider.ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+	if code := provider.ErrorCodeOf(err); code != provider.ErrorCodeUnauthorized {
+		t.Errorf("expected error code unauthorized, got %s", code)
+	}
+}
+
 func TestAdapter_Interface(t *testing.T) {
 	adapter := New()
 
@@ -118,3 +139,38 @@ func TestAdapter_ValidateAuth_MissingCredentials(t *testing.T) {
 		t.Error("expected error for missing credentials")
 	}
 }
+
+func TestWindowDuration(t *testing.T) {
+	tests := []struct {
+		duration int
+		unit     FlexibleString
+		want     time.Duration
+	}{
+		{300, "TIME_UNIT_MINUTE", 5 * time.Hour},
+		{300, "5", 5 * time.Hour},
+		{1, "TIME_UNIT_DAY", 24 * time.Hour},
+		{3, "TIME_UNIT_UNKNOWN", 0},
+	}
+	for _, tt := range tests {
+		if got := windowDuration(tt.duration, tt.unit); got != tt.want {
+			t.Errorf("windowDuration(%d, %q) = %s, want %s", tt.duration, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestMetricLabel(t *testing.T) {
+	tests := []struct {
+		duration int
+		unit     FlexibleString
+		want     string
+	}{
+		{300, "TIME_UNIT_MINUTE", "300m"},
+		{300, "5", "300m"},
+		{1, "TIME_UNIT_DAY", "1 TIME_UNIT_DAY"},
+	}
+	for _, tt := range tests {
+		if got := metricLabel(tt.duration, tt.unit); got != tt.want {
+			t.Errorf("metricLabel(%d, %q) = %q, want %q", tt.duration, tt.unit, got, tt.want)
+		}
+	}
+}
@@ -2,12 +2,13 @@ package minimax
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httputil"
 	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
 )
 
 var (
@@ -88,7 +89,7 @@ func (c *Client) doRequest(ctx context.Context, method, urlStr string) ([]byte,
 	c.logResponse(resp, body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, provider.NewHTTPError(resp)
 	}
 
 	return body, nil
@@ -105,7 +106,7 @@ func (c *Client) GetCurrentSubscribe(ctx context.Context) (*CurrentSubscribeResp
 	}
 
 	var result CurrentSubscribeResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := provider.DecodeJSON(body, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -121,8 +122,29 @@ func (c *Client) GetRemains(ctx context.Context) (*RemainsResponse, error) {
 	}
 
 	var result RemainsResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := provider.DecodeJSON(body, &result); err != nil {
+		return nil, err
+	}
+	if err := result.BaseResp.Err(); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
+
+// Err 将 base_resp 中的业务状态码映射为 provider 错误
+func (b BaseResp) Err() error {
+	switch b.StatusCode {
+	case 0:
+		return nil
+	case 1004:
+		return fmt.Errorf("%w: %s", provider.ErrUnauthorized, b.StatusMsg)
+	case 1002:
+		return fmt.Errorf("%w: %s", provider.ErrRateLimited, b.StatusMsg)
+	case 1001:
+		return fmt.Errorf("%w: %s", provider.ErrTimeout, b.StatusMsg)
+	case 1013:
+		return fmt.Errorf("%w: %s", provider.ErrUnavailable, b.StatusMsg)
+	default:
+		return fmt.Errorf("minimax status %d: %s", b.StatusCode, b.StatusMsg)
+	}
+}
@@ -2,6 +2,7 @@ package minimax
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -22,6 +23,11 @@ func (a *Adapter) DisplayName() string {
 	return "MiniMax"
 }
 
+// UsageRequests is the number of upstream requests per FetchUsage: subscription and remains.
+func (a *Adapter) UsageRequests() int {
+	return 2
+}
+
 func (a *Adapter) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		SupportsUsageMetrics:  true,
@@ -67,20 +73,21 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 		Status:      provider.StatusOK,
 	}
 
-	var errors []string
+	var errs []error
 	if err1 != nil {
-		errors = append(errors, fmt.Sprintf("get_subscribe: %v", err1))
+		errs = append(errs, fmt.Errorf("get_subscribe: %w", err1))
 	}
 	if err2 != nil {
-		errors = append(errors, fmt.Sprintf("get_remains: %v", err2))
+		errs = append(errs, fmt.Errorf("get_remains: %w", err2))
 	}
 
-	if len(errors) == 2 {
-		return nil, fmt.Errorf("all endpoints failed: %v", errors)
+	if len(errs) == 2 {
+		return nil, fmt.Errorf("all endpoints failed: %w", errors.Join(errs...))
 	}
-	if len(errors) > 0 {
+	if len(errs) > 0 {
 		snap.Status = provider.StatusError
-		snap.Error = fmt.Sprintf("partial data: %v", errors)
+		snap.Error = fmt.Sprintf("partial data: %v", errs)
+		snap.ErrorCode = provider.ErrorCodeOf(errors.Join(errs...))
 	}
 
 	if subResp != nil {
@@ -100,13 +107,20 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 
 			resetsAt := time.UnixMilli(remain.EndTime)
 
+			window := provider.UsageWindow{
+				ID:       "interval",
+				Label:    "Current Interval",
+				ResetsAt: &resetsAt,
+			}
+			if remain.StartTime > 0 && remain.EndTime > remain.StartTime {
+				window.StartsAt = provider.Ptr(time.UnixMilli(remain.StartTime))
+				window.Duration = time.Duration(remain.EndTime-remain.StartTime) * time.Millisecond
+				window.Label = provider.WindowLabel(window.Duration)
+			}
+
 			metrics = append(metrics, provider.UsageMetric{
-				Name: fmt.Sprintf("%s Usage", remain.ModelName),
-				Window: provider.UsageWindow{
-					ID:       "interval",
-					Label:    "Current Interval",
-					ResetsAt: &resetsAt,
-				},
+				Name:   fmt.Sprintf("%s Usage", remain.ModelName),
+				Window: window,
 				Amount: provider.UsageAmount{
 					Used:      provider.Ptr(float64(used)),
 					Limit:     provider.Ptr(float64(total)),
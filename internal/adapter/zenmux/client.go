@@ -8,7 +8,12 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
 )
 
 var (
@@ -104,9 +109,69 @@ func (c *Client) doRequest(ctx context.Context, method, urlStr string) ([]byte,
 	}
 	c.logResponse(resp, body)
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, provider.NewHTTPError(resp)
+	}
+
 	return body, nil
 }
 
+// apiStatus is the status part of ZenMux responses, which answer 200 and
+// report failures with success=false.
+type apiStatus struct {
+	Success bool            `json:"success"`
+	Code    json.RawMessage `json:"code"`
+	Message string          `json:"message"`
+	Msg     string          `json:"msg"`
+}
+
+// authCodes and authMessages identify success=false answers given when the
+// session cookies are no longer accepted.
+var (
+	authCodes    = []string{"401", "403", "NOT_LOGIN", "LOGIN_REQUIRED", "UNAUTHORIZED", "SESSION_EXPIRED"}
+	authMessages = []string{"login", "log in", "logged in", "unauthorized", "session expired", "token expired", "登录", "未授权", "过期"}
+)
+
+// decodeResponse decodes body into v and reports success=false answers. Only
+// known authentication failures are reported as unauthorized, other failures
+// are left unclassified.
+func decodeResponse(body []byte, v interface{}) error {
+	if err := provider.DecodeJSON(body, v); err != nil {
+		return err
+	}
+	var status apiStatus
+	if err := provider.DecodeJSON(body, &status); err != nil {
+		return err
+	}
+	if status.Success {
+		return nil
+	}
+
+	code := strings.Trim(string(status.Code), `"`)
+	if code == "null" {
+		code = ""
+	}
+	message := status.Message
+	if message == "" {
+		message = status.Msg
+	}
+	detail := strings.TrimSpace(code + " " + message)
+	if detail == "" {
+		detail = "no details"
+	}
+
+	if slices.Contains(authCodes, strings.ToUpper(code)) {
+		return fmt.Errorf("%w: zenmux responded with success=false: %s", provider.ErrUnauthorized, detail)
+	}
+	lower := strings.ToLower(message)
+	for _, m := range authMessages {
+		if strings.Contains(lower, m) {
+			return fmt.Errorf("%w: zenmux responded with success=false: %s", provider.ErrUnauthorized, detail)
+		}
+	}
+	return fmt.Errorf("zenmux responded with success=false: %s", detail)
+}
+
 func (c *Client) GetCurrentSubscription(ctx context.Context) (*CurrentSubscriptionResponse, error) {
 	u, _ := url.Parse(c.baseURL + "/api/subscription/get_current")
 	q := u.Query()
@@ -119,7 +184,7 @@ func (c *Client) GetCurrentSubscription(ctx context.Context) (*CurrentSubscripti
 	}
 
 	var result CurrentSubscriptionResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := decodeResponse(body, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -137,7 +202,7 @@ func (c *Client) GetCurrentUsage(ctx context.Context) (*CurrentUsageResponse, er
 	}
 
 	var result CurrentUsageResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := decodeResponse(body, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
@@ -155,8 +220,46 @@ func (c *Client) GetSubscriptionSummary(ctx context.Context) (*SubscriptionSumma
 	}
 
 	var result SubscriptionSummaryResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := decodeResponse(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetSubscriptionSummaryForPeriod 查询指定时间段内的费用汇总
+func (c *Client) GetSubscriptionSummaryForPeriod(ctx context.Context, start, end time.Time) (*SubscriptionSummaryResponse, error) {
+	body, err := c.doRequest(ctx, "GET", c.costQueryURL("/api/dashboard/cost/query/subscription_summary", start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	var result SubscriptionSummaryResponse
+	if err := decodeResponse(body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetModelSummary 查询指定时间段内按模型的费用
+func (c *Client) GetModelSummary(ctx context.Context, start, end time.Time) (*ModelSummaryResponse, error) {
+	body, err := c.doRequest(ctx, "GET", c.costQueryURL("/api/dashboard/cost/query/model_summary", start, end))
+	if err != nil {
+		return nil, err
+	}
+
+	var result ModelSummaryResponse
+	if err := decodeResponse(body, &result); err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
+
+func (c *Client) costQueryURL(path string, start, end time.Time) string {
+	u, _ := url.Parse(c.baseURL + path)
+	q := u.Query()
+	q.Set("ctoken", c.ctoken)
+	q.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	q.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
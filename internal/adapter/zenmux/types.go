@@ -82,6 +82,13 @@ type SummaryData struct {
 type SubscriptionDetail = CurrentSubscriptionData
 type UsageDetail = UsageItem
 type SummaryDetail = SummaryData
+
+// ModelSummaryResponse - 按模型的费用汇总
+type ModelSummaryResponse struct {
+	Success bool           `json:"success"`
+	Data    []ModelSummary `json:"data"`
+}
+
 type ModelSummary struct {
 	Model        string  `json:"model"`
 	Cost         float64 `json:"cost"`
@@ -2,8 +2,10 @@ package zenmux
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/user/subscriptions-monitor/internal/provider"
@@ -23,43 +25,36 @@ func (a *Adapter) DisplayName() string {
 	return "ZenMux"
 }
 
+// UsageRequests is the number of upstream requests per FetchUsage: subscription, usage and summary.
+func (a *Adapter) UsageRequests() int {
+	return 3
+}
+
 func (a *Adapter) Capabilities() provider.Capabilities {
 	return provider.Capabilities{
 		SupportsUsageMetrics:  true,
 		SupportsCostBreakdown: true,
-		SupportsCostByModel:   false,
+		SupportsCostByModel:   true,
 		AuthTypes:             []provider.AuthType{provider.AuthCookie},
 	}
 }
 
 func (a *Adapter) ValidateAuth(ctx context.Context, auth provider.AuthConfig) error {
-	ctoken := auth.Extra["ctoken"]
-	sessionID := auth.Extra["session_id"]
-	sessionIDSig := auth.Extra["session_id_sig"]
-
-	if ctoken == "" || sessionID == "" {
-		return fmt.Errorf("zenmux requires ctoken and session_id in auth.extra")
+	client, err := newClientFromAuth(auth)
+	if err != nil {
+		return err
 	}
 
-	client := NewClientWithSig(ctoken, sessionID, sessionIDSig)
-	client.Debug = false
-
-	_, err := client.GetCurrentSubscription(ctx)
+	_, err = client.GetCurrentSubscription(ctx)
 	return err
 }
 
 func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*provider.UsageSnapshot, error) {
-	ctoken := auth.Extra["ctoken"]
-	sessionID := auth.Extra["session_id"]
-	sessionIDSig := auth.Extra["session_id_sig"]
-
-	if ctoken == "" || sessionID == "" {
-		return nil, fmt.Errorf("zenmux requires ctoken and session_id in auth.extra")
+	client, err := newClientFromAuth(auth)
+	if err != nil {
+		return nil, err
 	}
 
-	client := NewClientWithSig(ctoken, sessionID, sessionIDSig)
-	client.Debug = false
-
 	subResp, err1 := client.GetCurrentSubscription(ctx)
 	usageResp, err2 := client.GetCurrentUsage(ctx)
 	summaryResp, err3 := client.GetSubscriptionSummary(ctx)
@@ -71,23 +66,24 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 		Status:      provider.StatusOK,
 	}
 
-	var errors []string
+	var errs []error
 	if err1 != nil {
-		errors = append(errors, fmt.Sprintf("get_current: %v", err1))
+		errs = append(errs, fmt.Errorf("get_current: %w", err1))
 	}
 	if err2 != nil {
-		errors = append(errors, fmt.Sprintf("get_current_usage: %v", err2))
+		errs = append(errs, fmt.Errorf("get_current_usage: %w", err2))
 	}
 	if err3 != nil {
-		errors = append(errors, fmt.Sprintf("subscription_summary: %v", err3))
+		errs = append(errs, fmt.Errorf("subscription_summary: %w", err3))
 	}
 
-	if len(errors) == 3 {
-		return nil, fmt.Errorf("all endpoints failed: %v", errors)
+	if len(errs) == 3 {
+		return nil, fmt.Errorf("all endpoints failed: %w", errors.Join(errs...))
 	}
-	if len(errors) > 0 {
+	if len(errs) > 0 {
 		snap.Status = provider.StatusError
-		snap.Error = fmt.Sprintf("partial data: %v", errors)
+		snap.Error = fmt.Sprintf("partial data: %v", errs)
+		snap.ErrorCode = provider.ErrorCodeOf(errors.Join(errs...))
 	}
 
 	if subResp != nil && subResp.Data != nil {
@@ -102,6 +98,13 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 
 	var metrics []provider.UsageMetric
 
+	// Without the subscription the quota is unknown, flows are then reported
+	// as the used percentage of the quota without a limit.
+	quota := 0
+	if subResp != nil && subResp.Data != nil {
+		quota = parseQuota(subResp.Data.Desc)
+	}
+
 	if usageResp != nil {
 		for _, item := range usageResp.Data {
 			var metricName, windowID, windowLabel string
@@ -121,24 +124,40 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 				windowLabel = item.PeriodType
 			}
 
-			quota := parseQuota(subResp.Data.Desc)
+			duration := windowDuration(item)
+			if label := provider.WindowLabel(duration); label != "" && windowLabel == item.PeriodType {
+				windowLabel = label
+			}
 
-			used := float64(quota) * item.UsedRate
-			remaining := float64(quota) - used
+			var startsAt *time.Time
+			if !item.CycleStartTime.IsZero() {
+				startsAt = &item.CycleStartTime
+			}
+
+			amount := provider.UsageAmount{
+				Used: provider.Ptr(item.UsedRate * 100),
+				Unit: "percent",
+			}
+			if quota > 0 {
+				used := float64(quota) * item.UsedRate
+				amount = provider.UsageAmount{
+					Used:      provider.Ptr(used),
+					Limit:     provider.Ptr(float64(quota)),
+					Remaining: provider.Ptr(float64(quota) - used),
+					Unit:      "flows",
+				}
+			}
 
 			metrics = append(metrics, provider.UsageMetric{
 				Name: metricName,
 				Window: provider.UsageWindow{
 					ID:       windowID,
 					Label:    windowLabel,
+					StartsAt: startsAt,
 					ResetsAt: &item.CycleEndTime,
+					Duration: duration,
 				},
-				Amount: provider.UsageAmount{
-					Used:      provider.Ptr(used),
-					Limit:     provider.Ptr(float64(quota)),
-					Remaining: provider.Ptr(remaining),
-					Unit:      "flows",
-				},
+				Amount: amount,
 			})
 		}
 	}
@@ -177,15 +196,93 @@ func (a *Adapter) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*pr
 		snap.Cost = &provider.CostBreakdown{
 			Currency: "USD",
 		}
-		if totalCost, err := strconv.ParseFloat(data.TotalCost, 64); err == nil {
-			snap.Cost.Total = totalCost
-		}
+		applySummaryCost(snap.Cost, data)
 	}
 
 	snap.Metrics = metrics
 	return snap, nil
 }
 
+// CostRequests is the number of upstream requests per FetchCosts: summary and
+// model summary.
+func (a *Adapter) CostRequests() int {
+	return 2
+}
+
+// FetchCosts returns the cost of the subscription for period, split by
+// input/output/other and by model. When only one of the two queries fails,
+// the part that succeeded is returned together with the error.
+func (a *Adapter) FetchCosts(ctx context.Context, auth provider.AuthConfig, period provider.TimePeriod) (*provider.CostBreakdown, error) {
+	client, err := newClientFromAuth(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	summaryResp, err1 := client.GetSubscriptionSummaryForPeriod(ctx, period.Start, period.End)
+	modelResp, err2 := client.GetModelSummary(ctx, period.Start, period.End)
+
+	var errs []error
+	if err1 != nil {
+		errs = append(errs, fmt.Errorf("subscription_summary: %w", err1))
+	}
+	if err2 != nil {
+		errs = append(errs, fmt.Errorf("model_summary: %w", err2))
+	}
+	if len(errs) == 2 {
+		return nil, fmt.Errorf("all endpoints failed: %w", errors.Join(errs...))
+	}
+
+	cost := &provider.CostBreakdown{
+		Currency: "USD",
+		Period:   period,
+	}
+
+	if summaryResp != nil && summaryResp.Data != nil {
+		applySummaryCost(cost, summaryResp.Data)
+	}
+
+	if modelResp != nil && len(modelResp.Data) > 0 {
+		cost.ByModel = make(map[string]float64, len(modelResp.Data))
+		for _, m := range modelResp.Data {
+			cost.ByModel[m.Model] += m.Cost
+		}
+	}
+
+	if len(errs) > 0 {
+		return cost, fmt.Errorf("partial data: %w", errors.Join(errs...))
+	}
+	return cost, nil
+}
+
+func newClientFromAuth(auth provider.AuthConfig) (*Client, error) {
+	ctoken := auth.Extra["ctoken"]
+	sessionID := auth.Extra["session_id"]
+	sessionIDSig := auth.Extra["session_id_sig"]
+
+	if ctoken == "" || sessionID == "" {
+		return nil, fmt.Errorf("zenmux requires ctoken and session_id in auth.extra")
+	}
+
+	client := NewClientWithSig(ctoken, sessionID, sessionIDSig)
+	client.Debug = false
+	return client, nil
+}
+
+func applySummaryCost(cost *provider.CostBreakdown, data *SummaryData) {
+	if v, err := strconv.ParseFloat(data.TotalCost, 64); err == nil {
+		cost.Total = v
+	}
+	if v, err := strconv.ParseFloat(data.InputCost, 64); err == nil {
+		cost.Input = v
+	}
+	if v, err := strconv.ParseFloat(data.OutputCost, 64); err == nil {
+		cost.Output = v
+	}
+	if v, err := strconv.ParseFloat(data.OtherCost, 64); err == nil {
+		cost.Other = v
+	}
+}
+
 func parseQuota(desc string) int {
 	var num int
 	fmt.Sscanf(desc, "%d", &num)
@@ -194,3 +291,21 @@ func parseQuota(desc string) int {
 	}
 	return num
 }
+
+// windowDuration returns the length of a usage cycle, preferring the reported
+// cycle bounds over periodDuration and the period type.
+func windowDuration(item UsageItem) time.Duration {
+	if !item.CycleStartTime.IsZero() && item.CycleEndTime.After(item.CycleStartTime) {
+		return item.CycleEndTime.Sub(item.CycleStartTime)
+	}
+	if d, err := time.ParseDuration(strings.ToLower(item.PeriodDuration)); err == nil && d > 0 {
+		return d
+	}
+	switch item.PeriodType {
+	case "hour_5":
+		return 5 * time.Hour
+	case "week":
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
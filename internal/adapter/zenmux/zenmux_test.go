@@ -0,0 +1,207 @@
+package zenmux
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// newCostServer answers the cost queries. failModels makes model_summary
+// fail with a server error.
+func newCostServer(t *testing.T, failModels bool) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("ctoken") != "test-ctoken" {
+			t.Errorf("unexpected ctoken: %s", query.Get("ctoken"))
+		}
+		if query.Get("startTime") == "" || query.Get("endTime") == "" {
+			t.Errorf("expected startTime and endTime on %s", r.URL.Path)
+		}
+
+		var resp interface{}
+		switch r.URL.Path {
+		case "/api/dashboard/cost/query/subscription_summary":
+			resp = SubscriptionSummaryResponse{
+				Success: true,
+				Data: &SummaryData{
+					TotalCost:  "12.50",
+					InputCost:  "8.00",
+					OutputCost: "4.00",
+					OtherCost:  "0.50",
+				},
+			}
+		case "/api/dashboard/cost/query/model_summary":
+			if failModels {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			resp = ModelSummaryResponse{
+				Success: true,
+				Data: []ModelSummary{
+					{Model: "anthropic/claude-sonnet-4", Cost: 10},
+					{Model: "openai/gpt-5", Cost: 2.5},
+				},
+			}
+		default:
+			t.Errorf("unexpected path: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func fetchTestCosts(t *testing.T, failModels bool) (*provider.CostBreakdown, provider.TimePeriod, error) {
+	t.Helper()
+	server := newCostServer(t, failModels)
+	t.Cleanup(server.Close)
+
+	originalBaseURL := baseURL
+	baseURL = server.URL
+	t.Cleanup(func() { baseURL = originalBaseURL })
+
+	auth := provider.AuthConfig{
+		Type: provider.AuthCookie,
+		Extra: map[string]string{
+			"ctoken":     "test-ctoken",
+			"session_id": "test-session",
+		},
+	}
+	period := provider.TimePeriod{
+		Start: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 2, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	var cp provider.CostProvider = New()
+	cost, err := cp.FetchCosts(context.Background(), auth, period)
+	return cost, period, err
+}
+
+func TestAdapter_FetchCosts(t *testing.T) {
+	cost, period, err := fetchTestCosts(t, false)
+	if err != nil {
+		t.Fatalf("FetchCosts failed: %v", err)
+	}
+
+	if cost.Total != 12.5 || cost.Input != 8 || cost.Output != 4 || cost.Other != 0.5 {
+		t.Errorf("unexpected cost split: %+v", cost)
+	}
+	if cost.ByModel["anthropic/claude-sonnet-4"] != 10 || cost.ByModel["openai/gpt-5"] != 2.5 {
+		t.Errorf("unexpected by-model costs: %v", cost.ByModel)
+	}
+	if !cost.Period.End.Equal(period.End) {
+		t.Errorf("expected period to be preserved, got %v", cost.Period)
+	}
+}
+
+func TestAdapter_FetchCosts_Partial(t *testing.T) {
+	cost, _, err := fetchTestCosts(t, true)
+	if err == nil || !strings.Contains(err.Error(), "model_summary") {
+		t.Errorf("expected a partial error for model_summary, got %v", err)
+	}
+	if provider.ErrorCodeOf(err) != provider.ErrorCodeUnavailable {
+		t.Errorf("expected the failure to be classified, got %q", provider.ErrorCodeOf(err))
+	}
+	if cost == nil || cost.Total != 12.5 || cost.ByModel != nil {
+		t.Errorf("expected the summary without models, got %+v", cost)
+	}
+}
+
+func TestAdapter_FetchUsage_UnknownQuota(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch r.URL.Path {
+		case "/api/subscription/get_current_usage":
+			resp = CurrentUsageResponse{
+				Success: true,
+				Data:    []UsageItem{{PeriodType: "hour_5", UsedRate: 0.25, CycleEndTime: time.Date(2026, 2, 1, 5, 0, 0, 0, time.UTC)}},
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	originalBaseURL := baseURL
+	baseURL = server.URL
+	defer func() { baseURL = originalBaseURL }()
+
+	auth := provider.AuthConfig{Extra: map[string]string{"ctoken": "test-ctoken", "session_id": "test-session"}}
+	snap, err := New().FetchUsage(context.Background(), auth)
+	if err != nil {
+		t.Fatalf("FetchUsage: %v", err)
+	}
+	if snap.Status != provider.StatusError || len(snap.Metrics) != 1 {
+		t.Fatalf("expected partial data with the usage metric, got %+v", snap)
+	}
+	amount := snap.Metrics[0].Amount
+	if amount.Used == nil || *amount.Used != 25 || amount.Limit != nil || amount.Unit != "percent" {
+		t.Errorf("expected 25 percent without a limit, got %+v", amount)
+	}
+}
+
+func TestAdapter_FetchCosts_MissingCredentials(t *testing.T) {
+	auth := provider.AuthConfig{
+		Type:  provider.AuthCookie,
+		Extra: map[string]string{},
+	}
+
+	_, err := New().FetchCosts(context.Background(), auth, provider.TimePeriod{})
+	if err == nil {
+		t.Error("expected error for missing credentials")
+	}
+}
+
+func TestWindowDuration(t *testing.T) {
+	start := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		item UsageItem
+		want time.Duration
+	}{
+		{UsageItem{PeriodType: "hour_5", CycleStartTime: start, CycleEndTime: start.Add(5 * time.Hour)}, 5 * time.Hour},
+		{UsageItem{PeriodType: "custom", PeriodDuration: "12h"}, 12 * time.Hour},
+		{UsageItem{PeriodType: "week"}, 7 * 24 * time.Hour},
+		{UsageItem{PeriodType: "custom"}, 0},
+	}
+	for _, tt := range tests {
+		if got := windowDuration(tt.item); got != tt.want {
+			t.Errorf("windowDuration(%+v) = %s, want %s", tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeResponse_SuccessFalse(t *testing.T) {
+	tests := []struct {
+		body string
+		want provider.ErrorCode
+	}{
+		{`{"success":true,"data":null}`, ""},
+		{`{"success":false,"code":"NOT_LOGIN","message":"please log in"}`, provider.ErrorCodeUnauthorized},
+		{`{"success":false,"code":401}`, provider.ErrorCodeUnauthorized},
+		{`{"success":false,"msg":"登录已过期"}`, provider.ErrorCodeUnauthorized},
+		{`{"success":false,"code":"PARAM_INVALID","message":"startTime is out of range"}`, provider.ErrorCodeUnknown},
+		{`{"success":false,"code":500,"message":"system busy"}`, provider.ErrorCodeUnknown},
+		{`{"success":false}`, provider.ErrorCodeUnknown},
+	}
+	for _, tt := range tests {
+		var result SubscriptionSummaryResponse
+		err := decodeResponse([]byte(tt.body), &result)
+		if got := provider.ErrorCodeOf(err); got != tt.want {
+			t.Errorf("%s: got error code %q (%v), want %q", tt.body, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+	"github.com/user/subscriptions-monitor/internal/store"
+)
+
+// StateFile is the name of the alert state file in the data directory
+const StateFile = "alerts.json"
+
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert is an instance of a rule for one subscription, and for metric rules
+// one metric. Value is the observed value of the rule's field at the latest
+// evaluation.
+type Alert struct {
+	Rule         string     `json:"rule"`
+	Severity     Severity   `json:"severity"`
+	Subscription string     `json:"subscription"`
+	Provider     string     `json:"provider"`
+	Metric       string     `json:"metric,omitempty"`
+	When         string     `json:"when"`
+	Value        string     `json:"value"`
+	State        State      `json:"state"`
+	ActiveAt     time.Time  `json:"active_at"`
+	FiredAt      *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Key identifies the alert across evaluations.
+func (a Alert) Key() string {
+	return a.Rule + "/" + a.Provider + "/" + a.Subscription + "/" + a.Metric
+}
+
+// Summary is a one-line description of the alert.
+func (a Alert) Summary() string {
+	target := a.Subscription
+	if a.Metric != "" {
+		target += " " + a.Metric
+	}
+	return fmt.Sprintf("%s: %s (%s, value %s)", a.Rule, target, a.When, a.Value)
+}
+
+// Event reports a state change that should be notified: an alert started
+// firing or a firing alert resolved.
+type Event struct {
+	State State `json:"state"`
+	Alert Alert `json:"alert"`
+}
+
+type compiledRule struct {
+	Rule
+	cond condition
+}
+
+// Engine evaluates rules against refreshed snapshots and tracks the
+// resulting alerts through pending, firing and resolved. An alert whose
+// condition stops holding while pending is dropped without an event, and an
+// alert only produces an event when it changes state, so repeated
+// evaluations do not notify twice.
+type Engine struct {
+	mu     sync.Mutex
+	rules  []compiledRule
+	active map[string]*Alert
+}
+
+// NewEngine compiles rules. Invalid rules are rejected by config.Load, they
+// are skipped here.
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{active: make(map[string]*Alert)}
+	for _, r := range rules {
+		cond, err := parseCondition(r.When)
+		if err != nil {
+			continue
+		}
+		e.rules = append(e.rules, compiledRule{Rule: r, cond: cond})
+	}
+	return e
+}
+
+// Restore seeds the engine with persisted alerts so that alerts which were
+// firing before a restart are not notified again. Alerts of rules or
+// subscriptions that no longer exist are dropped.
+func (e *Engine) Restore(alerts []Alert, subs []provider.SubscriptionEntry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	rules := make(map[string]bool, len(e.rules))
+	for _, r := range e.rules {
+		rules[r.Name] = true
+	}
+	configured := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		configured[sub.Provider+"/"+sub.Name] = true
+	}
+	for _, a := range alerts {
+		if !rules[a.Rule] || !configured[a.Provider+"/"+a.Subscription] {
+			continue
+		}
+		if a.State == StatePending || a.State == StateFiring {
+			a := a
+			e.active[a.Key()] = &a
+		}
+	}
+}
+
+// Evaluate applies all rules to snap and returns the resulting events.
+func (e *Engine) Evaluate(snap provider.UsageSnapshot, now time.Time) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var events []Event
+
+	for _, r := range e.rules {
+		if !r.matches(snap) {
+			continue
+		}
+
+		if !r.cond.perMetric() {
+			value, holds := r.cond.evalSnapshot(snap)
+			a := e.instance(r, snap, "")
+			if holds {
+				seen[a.Key()] = true
+				events = appendEvent(events, e.hold(r, a, value, now))
+			}
+			continue
+		}
+
+		for _, m := range snap.Metrics {
+			if r.Metric != "" && r.Metric != m.Name {
+				continue
+			}
+			value, holds, ok := r.cond.evalMetric(m)
+			if !ok || !holds {
+				continue
+			}
+			a := e.instance(r, snap, m.Name)
+			seen[a.Key()] = true
+			events = appendEvent(events, e.hold(r, a, strconv.FormatFloat(value, 'f', -1, 64), now))
+		}
+	}
+
+	// A failed refresh without metrics says nothing about metric conditions,
+	// keep their alerts as they are.
+	keepMetrics := snap.Status != provider.StatusOK && len(snap.Metrics) == 0
+
+	// Alerts of this subscription whose condition no longer holds.
+	for key, a := range e.active {
+		if a.Subscription != snap.Name || seen[key] || (keepMetrics && a.Metric != "") {
+			continue
+		}
+		delete(e.active, key)
+		if a.State == StateFiring {
+			a.State = StateResolved
+			a.ResolvedAt = provider.Ptr(now)
+			events = append(events, Event{State: StateResolved, Alert: *a})
+		}
+	}
+
+	return events
+}
+
+func appendEvent(events []Event, ev *Event) []Event {
+	if ev == nil {
+		return events
+	}
+	return append(events, *ev)
+}
+
+// instance returns the tracked alert for the rule and target, or a new
+// pending one that is not tracked yet.
+func (e *Engine) instance(r compiledRule, snap provider.UsageSnapshot, metric string) *Alert {
+	a := &Alert{
+		Rule:         r.Name,
+		Severity:     r.severity(),
+		Subscription: snap.Name,
+		Provider:     snap.ProviderID,
+		Metric:       metric,
+		When:         r.When,
+	}
+	if existing, ok := e.active[a.Key()]; ok {
+		return existing
+	}
+	return a
+}
+
+// hold advances an alert whose condition holds and returns the event of a
+// transition to firing.
+func (e *Engine) hold(r compiledRule, a *Alert, value string, now time.Time) *Event {
+	a.Value = value
+	if _, tracked := e.active[a.Key()]; !tracked {
+		a.State = StatePending
+		a.ActiveAt = now
+		e.active[a.Key()] = a
+	}
+
+	if a.State == StatePending && now.Sub(a.ActiveAt) >= r.For {
+		a.State = StateFiring
+		a.FiredAt = provider.Ptr(now)
+		return &Event{State: StateFiring, Alert: *a}
+	}
+	return nil
+}
+
+// Active returns the pending and firing alerts, firing first.
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.active))
+	for _, a := range e.active {
+		alerts = append(alerts, *a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].State != alerts[j].State {
+			return alerts[i].State == StateFiring
+		}
+		return alerts[i].Key() < alerts[j].Key()
+	})
+	return alerts
+}
+
+// LoadState reads the alerts saved by SaveState. A missing file yields no
+// alerts.
+func LoadState(path string) ([]Alert, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	if err := json.Unmarshal(data, &alerts); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return alerts, nil
+}
+
+// SaveState atomically replaces the alert state file at path.
+func SaveState(path string, alerts []Alert) error {
+	data, err := json.MarshalIndent(alerts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.WriteFileAtomic(path, data, 0640)
+}
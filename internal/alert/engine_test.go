@@ -0,0 +1,152 @@
+package alert
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func usageSnap(used float64) provider.UsageSnapshot {
+	return provider.UsageSnapshot{
+		ProviderID: "kimi",
+		Name:       "sub-a",
+		Status:     provider.StatusOK,
+		Metrics: []provider.UsageMetric{{
+			Name:   "5h",
+			Amount: provider.UsageAmount{Used: provider.Ptr(used), Limit: provider.Ptr(100.0)},
+		}},
+	}
+}
+
+func TestEngine_FiresAfterForAndResolves(t *testing.T) {
+	e := NewEngine([]Rule{{Name: "high", When: "used_percent >= 90", For: 10 * time.Minute}})
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if ev := e.Evaluate(usageSnap(95), base); len(ev) != 0 {
+		t.Fatalf("expected no event while pending, got %+v", ev)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].State != StatePending || active[0].Metric != "5h" {
+		t.Fatalf("expected one pending alert, got %+v", active)
+	}
+
+	if ev := e.Evaluate(usageSnap(96), base.Add(5*time.Minute)); len(ev) != 0 {
+		t.Fatalf("expected no event before for elapsed, got %+v", ev)
+	}
+
+	ev := e.Evaluate(usageSnap(97), base.Add(10*time.Minute))
+	if len(ev) != 1 || ev[0].State != StateFiring || ev[0].Alert.Value != "97" {
+		t.Fatalf("expected a firing event, got %+v", ev)
+	}
+
+	if ev := e.Evaluate(usageSnap(98), base.Add(15*time.Minute)); len(ev) != 0 {
+		t.Fatalf("expected a firing alert not to notify again, got %+v", ev)
+	}
+
+	ev = e.Evaluate(usageSnap(10), base.Add(20*time.Minute))
+	if len(ev) != 1 || ev[0].State != StateResolved || ev[0].Alert.ResolvedAt == nil {
+		t.Fatalf("expected a resolved event, got %+v", ev)
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Errorf("expected no active alerts, got %+v", active)
+	}
+}
+
+func TestEngine_PendingDroppedSilently(t *testing.T) {
+	e := NewEngine([]Rule{{Name: "high", When: "used_percent >= 90", For: time.Hour}})
+	now := time.Now()
+
+	e.Evaluate(usageSnap(95), now)
+	if ev := e.Evaluate(usageSnap(50), now.Add(time.Minute)); len(ev) != 0 {
+		t.Errorf("expected a pending alert to clear without an event, got %+v", ev)
+	}
+	if active := e.Active(); len(active) != 0 {
+		t.Errorf("expected no active alerts, got %+v", active)
+	}
+}
+
+func TestEngine_SnapshotRule(t *testing.T) {
+	e := NewEngine([]Rule{{Name: "unauthorized", When: "status == unauthorized", Provider: "kimi", Severity: SeverityCritical}})
+	now := time.Now()
+
+	failed := provider.UsageSnapshot{ProviderID: "kimi", Name: "sub-a", Status: provider.StatusUnauthorized}
+	ev := e.Evaluate(failed, now)
+	if len(ev) != 1 || ev[0].State != StateFiring || ev[0].Alert.Severity != SeverityCritical || ev[0].Alert.Value != "unauthorized" {
+		t.Fatalf("expected a firing event, got %+v", ev)
+	}
+
+	other := provider.UsageSnapshot{ProviderID: "zenmux", Name: "sub-b", Status: provider.StatusUnauthorized}
+	if ev := e.Evaluate(other, now); len(ev) != 0 {
+		t.Errorf("expected the provider filter to skip sub-b, got %+v", ev)
+	}
+
+	if ev := e.Evaluate(usageSnap(10), now.Add(time.Minute)); len(ev) != 1 || ev[0].State != StateResolved {
+		t.Errorf("expected a resolved event, got %+v", ev)
+	}
+}
+
+func TestEngine_FailedRefreshKeepsMetricAlerts(t *testing.T) {
+	e := NewEngine([]Rule{{Name: "high", When: "used_percent >= 90"}})
+	now := time.Now()
+
+	e.Evaluate(usageSnap(95), now)
+	failed := provider.UsageSnapshot{ProviderID: "kimi", Name: "sub-a", Status: provider.StatusError, Metrics: []provider.UsageMetric{}}
+	if ev := e.Evaluate(failed, now.Add(time.Minute)); len(ev) != 0 {
+		t.Errorf("expected a failed refresh not to resolve metric alerts, got %+v", ev)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].State != StateFiring {
+		t.Errorf("expected the alert to keep firing, got %+v", active)
+	}
+}
+
+func TestEngine_RestoreDoesNotRenotify(t *testing.T) {
+	rules := []Rule{{Name: "high", When: "used_percent >= 90"}}
+	path := filepath.Join(t.TempDir(), StateFile)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	e := NewEngine(rules)
+	if ev := e.Evaluate(usageSnap(95), now); len(ev) != 1 {
+		t.Fatalf("expected a firing event, got %+v", ev)
+	}
+	if err := SaveState(path, e.Active()); err != nil {
+		t.Fatal(err)
+	}
+
+	alerts, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := []provider.SubscriptionEntry{{Provider: "kimi", Name: "sub-a"}}
+	restarted := NewEngine(rules)
+	restarted.Restore(alerts, subs)
+
+	if ev := restarted.Evaluate(usageSnap(96), now.Add(time.Minute)); len(ev) != 0 {
+		t.Errorf("expected no event after restart, got %+v", ev)
+	}
+	active := restarted.Active()
+	if len(active) != 1 || !active[0].ActiveAt.Equal(now) {
+		t.Errorf("expected the restored alert to keep its start time, got %+v", active)
+	}
+
+	// Alerts of rules that were removed from the config are dropped.
+	removed := NewEngine([]Rule{{Name: "other", When: "remaining < 5"}})
+	removed.Restore(alerts, subs)
+	if active := removed.Active(); len(active) != 0 {
+		t.Errorf("expected alerts of removed rules to be dropped, got %+v", active)
+	}
+
+	// So are alerts of removed subscriptions.
+	unsubscribed := NewEngine(rules)
+	unsubscribed.Restore(alerts, []provider.SubscriptionEntry{{Provider: "minimax", Name: "sub-a"}})
+	if active := unsubscribed.Active(); len(active) != 0 {
+		t.Errorf("expected alerts of removed subscriptions to be dropped, got %+v", active)
+	}
+}
+
+func TestLoadState_Missing(t *testing.T) {
+	alerts, err := LoadState(filepath.Join(t.TempDir(), StateFile))
+	if err != nil || alerts != nil {
+		t.Errorf("expected no alerts and no error, got %+v, %v", alerts, err)
+	}
+}
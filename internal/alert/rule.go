@@ -0,0 +1,204 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Rule is an alert rule from the config. When is a comparison of a field
+// with a value, e.g. "used_percent >= 90", "remaining < 50" or
+// "status == unauthorized". Metric fields (used, limit, remaining,
+// used_percent, pace_delta) are evaluated per metric, snapshot fields
+// (status, error_code, stale) once per subscription. The rule fires once the
+// condition has held for For.
+type Rule struct {
+	Name         string        `yaml:"name" mapstructure:"name" json:"name"`
+	When         string        `yaml:"when" mapstructure:"when" json:"when"`
+	For          time.Duration `yaml:"for,omitempty" mapstructure:"for" json:"for,omitempty"`
+	Severity     Severity      `yaml:"severity,omitempty" mapstructure:"severity" json:"severity,omitempty"`
+	Subscription string        `yaml:"subscription,omitempty" mapstructure:"subscription" json:"subscription,omitempty"`
+	Provider     string        `yaml:"provider,omitempty" mapstructure:"provider" json:"provider,omitempty"`
+	Metric       string        `yaml:"metric,omitempty" mapstructure:"metric" json:"metric,omitempty"`
+}
+
+// Validate checks that the rule has a name, a valid condition and severity.
+func (r Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("alert rule needs a name")
+	}
+	if _, err := parseCondition(r.When); err != nil {
+		return fmt.Errorf("alert %q: %w", r.Name, err)
+	}
+	switch r.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return fmt.Errorf("alert %q: unknown severity %q", r.Name, r.Severity)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("alert %q: for must not be negative", r.Name)
+	}
+	return nil
+}
+
+func (r Rule) severity() Severity {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+func (r Rule) matches(snap provider.UsageSnapshot) bool {
+	if r.Subscription != "" && r.Subscription != snap.Name {
+		return false
+	}
+	if r.Provider != "" && r.Provider != snap.ProviderID {
+		return false
+	}
+	return true
+}
+
+var metricFields = map[string]bool{
+	"used":         true,
+	"limit":        true,
+	"remaining":    true,
+	"used_percent": true,
+	"pace_delta":   true,
+}
+
+var snapshotFields = map[string]bool{
+	"status":     true,
+	"error_code": true,
+	"stale":      true,
+}
+
+// operators are ordered so that two character operators are matched first.
+var operators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+type condition struct {
+	field string
+	op    string
+	num   float64
+	str   string
+	isNum bool
+}
+
+func parseCondition(s string) (condition, error) {
+	s = strings.TrimSpace(s)
+	for _, op := range operators {
+		i := strings.Index(s, op)
+		if i < 0 {
+			continue
+		}
+
+		c := condition{
+			field: strings.TrimSpace(s[:i]),
+			op:    op,
+			str:   strings.Trim(strings.TrimSpace(s[i+len(op):]), `"'`),
+		}
+		if !metricFields[c.field] && !snapshotFields[c.field] {
+			return c, fmt.Errorf("unknown field %q in %q", c.field, s)
+		}
+		if c.str == "" {
+			return c, fmt.Errorf("missing value in %q", s)
+		}
+
+		if metricFields[c.field] {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(c.str, "%"), 64)
+			if err != nil {
+				return c, fmt.Errorf("%s needs a number, got %q", c.field, c.str)
+			}
+			c.num, c.isNum = n, true
+		} else if op != "==" && op != "!=" {
+			return c, fmt.Errorf("%s only supports == and !=", c.field)
+		}
+		return c, nil
+	}
+	return condition{}, fmt.Errorf("invalid condition %q, expected e.g. \"used_percent >= 90\"", s)
+}
+
+func (c condition) perMetric() bool {
+	return metricFields[c.field]
+}
+
+// evalMetric returns the field's value and whether the condition holds. ok is
+// false when the metric does not report the field.
+func (c condition) evalMetric(m provider.UsageMetric) (value float64, holds, ok bool) {
+	a := m.Amount
+	switch c.field {
+	case "used":
+		if a.Used == nil {
+			return 0, false, false
+		}
+		value = *a.Used
+	case "limit":
+		if a.Limit == nil {
+			return 0, false, false
+		}
+		value = *a.Limit
+	case "remaining":
+		switch {
+		case a.Remaining != nil:
+			value = *a.Remaining
+		case a.Used != nil && a.Limit != nil:
+			value = *a.Limit - *a.Used
+		default:
+			return 0, false, false
+		}
+	case "used_percent":
+		if a.Used == nil || a.Limit == nil || *a.Limit <= 0 {
+			return 0, false, false
+		}
+		value = *a.Used / *a.Limit * 100
+	case "pace_delta":
+		if m.PaceDelta == nil {
+			return 0, false, false
+		}
+		value = *m.PaceDelta
+	}
+	return value, compare(value, c.op, c.num), true
+}
+
+func (c condition) evalSnapshot(snap provider.UsageSnapshot) (value string, holds bool) {
+	switch c.field {
+	case "status":
+		value = string(snap.Status)
+	case "error_code":
+		value = string(snap.ErrorCode)
+	case "stale":
+		value = strconv.FormatBool(snap.Stale)
+	}
+	if c.op == "==" {
+		return value, value == c.str
+	}
+	return value, value != c.str
+}
+
+func compare(v float64, op string, threshold float64) bool {
+	switch op {
+	case ">=":
+		return v >= threshold
+	case "<=":
+		return v <= threshold
+	case ">":
+		return v > threshold
+	case "<":
+		return v < threshold
+	case "==":
+		return v == threshold
+	case "!=":
+		return v != threshold
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestParseCondition(t *testing.T) {
+	valid := []string{
+		"used_percent >= 90",
+		"used_percent>=90%",
+		"remaining < 50",
+		"pace_delta > 0.2",
+		"status == unauthorized",
+		"error_code != 'rate_limited'",
+		"stale == true",
+	}
+	for _, s := range valid {
+		if _, err := parseCondition(s); err != nil {
+			t.Errorf("parseCondition(%q): %v", s, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"used_percent",
+		"cost >= 10",
+		"remaining < lots",
+		"status > ok",
+		"used >= ",
+	}
+	for _, s := range invalid {
+		if _, err := parseCondition(s); err == nil {
+			t.Errorf("parseCondition(%q): expected an error", s)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	if err := (Rule{Name: "high", When: "used_percent >= 90"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (Rule{When: "used_percent >= 90"}).Validate(); err == nil {
+		t.Error("expected an error for a rule without a name")
+	}
+	if err := (Rule{Name: "high", When: "used_percent >= 90", Severity: "fatal"}).Validate(); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+}
+
+func TestConditionEvalMetric(t *testing.T) {
+	m := provider.UsageMetric{
+		Name:   "5h",
+		Amount: provider.UsageAmount{Used: provider.Ptr(45.0), Limit: provider.Ptr(50.0)},
+	}
+
+	tests := []struct {
+		when  string
+		value float64
+		holds bool
+		ok    bool
+	}{
+		{"used_percent >= 90", 90, true, true},
+		{"remaining < 10", 5, true, true},
+		{"used > 45", 45, false, true},
+		{"pace_delta > 0", 0, false, false},
+	}
+	for _, tt := range tests {
+		c, err := parseCondition(tt.when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, holds, ok := c.evalMetric(m)
+		if value != tt.value || holds != tt.holds || ok != tt.ok {
+			t.Errorf("%s: got (%v, %v, %v), want (%v, %v, %v)", tt.when, value, holds, ok, tt.value, tt.holds, tt.ok)
+		}
+	}
+}
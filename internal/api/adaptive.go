@@ -0,0 +1,119 @@
+package api
+
+import (
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// resetGrace is how long after a window reset the next poll is scheduled, so
+// that the provider has already rolled over when we ask.
+const resetGrace = 5 * time.Second
+
+// adaptiveInterval picks the delay until the next poll of a subscription:
+//   - until just after the reset once a metric's limit is used up,
+//   - the minimum interval once any metric reaches the high usage ratio,
+//   - double the previous delay while usage has not changed since prev,
+//   - the base interval otherwise,
+//
+// then shortened to land just after an imminent window reset and clamped to
+// the configured bounds.
+func adaptiveInterval(base time.Duration, p config.AdaptivePolling, snap provider.UsageSnapshot, prev *provider.UsageSnapshot, last time.Duration, now time.Time) time.Duration {
+	if snap.Status != provider.StatusOK {
+		return clampInterval(base, p)
+	}
+
+	ratio := maxUsageRatio(snap)
+	delay := base
+	switch {
+	case ratio >= 1:
+		// Nothing changes before the used up window resets.
+		if untilReset, ok := exhaustedReset(snap, now); ok {
+			return clampInterval(untilReset+resetGrace, p)
+		}
+	case p.HighUsage > 0 && ratio >= p.HighUsage:
+		delay = p.MinInterval
+	case prev != nil && prev.Status == provider.StatusOK && sameUsage(snap, *prev):
+		if last < base {
+			last = base
+		}
+		delay = last * 2
+	}
+
+	for _, m := range snap.Metrics {
+		if m.Window.ResetsAt == nil {
+			continue
+		}
+		untilReset := m.Window.ResetsAt.Sub(now) + resetGrace
+		if untilReset > resetGrace && untilReset < delay {
+			delay = untilReset
+		}
+	}
+
+	return clampInterval(delay, p)
+}
+
+func clampInterval(d time.Duration, p config.AdaptivePolling) time.Duration {
+	if p.MinInterval > 0 && d < p.MinInterval {
+		d = p.MinInterval
+	}
+	if p.MaxInterval > 0 && d > p.MaxInterval {
+		d = p.MaxInterval
+	}
+	return d
+}
+
+func maxUsageRatio(snap provider.UsageSnapshot) float64 {
+	var ratio float64
+	for _, m := range snap.Metrics {
+		if m.Amount.Used == nil || m.Amount.Limit == nil || *m.Amount.Limit <= 0 {
+			continue
+		}
+		if r := *m.Amount.Used / *m.Amount.Limit; r > ratio {
+			ratio = r
+		}
+	}
+	return ratio
+}
+
+// exhaustedReset returns the time until the last reset of the metrics whose
+// limit is used up, when all of them report one.
+func exhaustedReset(snap provider.UsageSnapshot, now time.Time) (time.Duration, bool) {
+	var until time.Duration
+	found := false
+	for _, m := range snap.Metrics {
+		if m.Amount.Used == nil || m.Amount.Limit == nil || *m.Amount.Limit <= 0 || *m.Amount.Used < *m.Amount.Limit {
+			continue
+		}
+		if m.Window.ResetsAt == nil {
+			return 0, false
+		}
+		if d := m.Window.ResetsAt.Sub(now); !found || d > until {
+			until, found = d, true
+		}
+	}
+	return max(until, 0), found
+}
+
+func sameUsage(a, b provider.UsageSnapshot) bool {
+	if len(a.Metrics) != len(b.Metrics) {
+		return false
+	}
+
+	used := make(map[string]float64, len(b.Metrics))
+	for _, m := range b.Metrics {
+		if m.Amount.Used != nil {
+			used[m.Name] = *m.Amount.Used
+		}
+	}
+	for _, m := range a.Metrics {
+		if m.Amount.Used == nil {
+			continue
+		}
+		if v, ok := used[m.Name]; !ok || v != *m.Amount.Used {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func usageSnapshot(used, limit float64, resetsAt *time.Time) provider.UsageSnapshot {
+	return provider.UsageSnapshot{
+		Status: provider.StatusOK,
+		Metrics: []provider.UsageMetric{
+			{
+				Name:   "5h Flows",
+				Window: provider.UsageWindow{ID: "5h", ResetsAt: resetsAt},
+				Amount: provider.UsageAmount{Used: provider.Ptr(used), Limit: provider.Ptr(limit)},
+			},
+		},
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	base := time.Minute
+	policy := config.AdaptivePolling{
+		Enabled:     true,
+		MinInterval: 15 * time.Second,
+		MaxInterval: 10 * time.Minute,
+		HighUsage:   0.8,
+	}
+
+	tests := []struct {
+		name string
+		snap provider.UsageSnapshot
+		prev *provider.UsageSnapshot
+		last time.Duration
+		want time.Duration
+	}{
+		{
+			name: "normal usage keeps the base interval",
+			snap: usageSnapshot(20, 100, nil),
+			prev: provider.Ptr(usageSnapshot(10, 100, nil)),
+			last: base,
+			want: base,
+		},
+		{
+			name: "high usage polls at the minimum interval",
+			snap: usageSnapshot(95, 100, nil),
+			last: base,
+			want: 15 * time.Second,
+		},
+		{
+			name: "used up limit sleeps until the reset",
+			snap: usageSnapshot(100, 100, provider.Ptr(now.Add(4*time.Minute))),
+			last: base,
+			want: 4*time.Minute + resetGrace,
+		},
+		{
+			name: "sleep until a distant reset is capped",
+			snap: usageSnapshot(100, 100, provider.Ptr(now.Add(3*time.Hour))),
+			last: base,
+			want: 10 * time.Minute,
+		},
+		{
+			name: "idle usage backs off",
+			snap: usageSnapshot(2, 100, nil),
+			prev: provider.Ptr(usageSnapshot(2, 100, nil)),
+			last: 4 * time.Minute,
+			want: 8 * time.Minute,
+		},
+		{
+			name: "idle backoff is capped",
+			snap: usageSnapshot(2, 100, nil),
+			prev: provider.Ptr(usageSnapshot(2, 100, nil)),
+			last: 8 * time.Minute,
+			want: 10 * time.Minute,
+		},
+		{
+			name: "imminent reset schedules the poll right after it",
+			snap: usageSnapshot(50, 100, provider.Ptr(now.Add(30*time.Second))),
+			last: base,
+			want: 30*time.Second + resetGrace,
+		},
+		{
+			name: "failed fetch falls back to the base interval",
+			snap: provider.UsageSnapshot{Status: provider.StatusUnavailable},
+			last: 8 * time.Minute,
+			want: base,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := adaptiveInterval(base, policy, tt.snap, tt.prev, tt.last, now)
+			if got != tt.want {
+				t.Errorf("adaptiveInterval() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
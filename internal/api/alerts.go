@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/notify"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func (s *Server) alertsPath() string {
+	return filepath.Join(s.dataDir, alert.StateFile)
+}
+
+// loadAlerts creates the alert engine and restores the alerts that were
+// pending or firing when the server last stopped, so that a restart neither
+// notifies them again nor restarts their for duration.
+func (s *Server) loadAlerts() {
+	if len(s.config.Alerts) == 0 {
+		return
+	}
+
+	s.alerts = alert.NewEngine(s.config.Alerts)
+	alerts, err := alert.LoadState(s.alertsPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load alert state: %v\n", err)
+		return
+	}
+	s.alerts.Restore(alerts, s.config.Subscriptions)
+}
+
+// loadThreads restores the messages of the threads notifiers left open, so
+// that an alert resolving after a restart still updates its message. Without
+// a readable state file they are kept in memory only.
+func (s *Server) loadThreads() *notify.ThreadStore {
+	threads, err := notify.LoadThreads(filepath.Join(s.dataDir, notify.ThreadsFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load notifier threads: %v\n", err)
+		return nil
+	}
+	return threads
+}
+
+// evaluateAlerts applies the alert rules to a refreshed snapshot, notifies
+// the alerts that started firing or resolved and persists the alert state.
+func (s *Server) evaluateAlerts(snap provider.UsageSnapshot) {
+	if s.alerts == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, ev := range s.alerts.Evaluate(snap, now) {
+		fmt.Printf("Alert %s [%s]: %s\n", ev.State, ev.Alert.Severity, ev.Alert.Summary())
+		s.dispatch(notify.AlertEvent(ev, snap, now))
+	}
+
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	if err := alert.SaveState(s.alertsPath(), s.alerts.Active()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to persist alert state: %v\n", err)
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/notify"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestAlertsHandler(t *testing.T) {
+	p := &countingProvider{}
+	registry := provider.NewRegistry()
+	registry.Register(p)
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.DataDir = t.TempDir()
+	cfg.Subscriptions = []provider.SubscriptionEntry{{Provider: p.ID(), Name: "sub-a"}}
+	cfg.Alerts = []alert.Rule{{Name: "ok", When: "status == ok"}}
+
+	s := NewServer(registry, cfg, "127.0.0.1:0")
+	s.refresh(cfg.Subscriptions[0])
+
+	rec := httptest.NewRecorder()
+	s.alertsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts?state=firing", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var alerts []alert.Alert
+	if err := json.NewDecoder(rec.Body).Decode(&alerts); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].Rule != "ok" || alerts[0].Subscription != "sub-a" {
+		t.Fatalf("unexpected alerts: %+v", alerts)
+	}
+
+	saved, err := alert.LoadState(filepath.Join(cfg.Settings.DataDir, alert.StateFile))
+	if err != nil || len(saved) != 1 {
+		t.Errorf("expected the alert state to be persisted, got %+v, %v", saved, err)
+	}
+
+	rec = httptest.NewRecorder()
+	s.alertsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts?state=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid state, got %d", rec.Code)
+	}
+}
+
+func TestAlertsHandler_NoRules(t *testing.T) {
+	s := newTestServer(t, &countingProvider{})
+
+	rec := httptest.NewRecorder()
+	s.alertsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil))
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Errorf("expected an empty list, got %q", body)
+	}
+}
+
+type flakyProvider struct {
+	countingProvider
+	fail atomic.Bool
+}
+
+func (p *flakyProvider) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*provider.UsageSnapshot, error) {
+	if p.fail.Load() {
+		return nil, provider.ErrUnauthorized
+	}
+	return p.countingProvider.FetchUsage(ctx, auth)
+}
+
+// newNotifyingServer serves p's subscription sub-a with a webhook notifier
+// that receives kinds (the defaults when empty) on the returned channel.
+func newNotifyingServer(t *testing.T, p provider.Provider, kinds []notify.Kind) (*Server, chan notify.Event) {
+	t.Helper()
+	events := make(chan notify.Event, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev notify.Event
+		json.NewDecoder(r.Body).Decode(&ev)
+		events <- ev
+	}))
+	t.Cleanup(hook.Close)
+
+	registry := provider.NewRegistry()
+	registry.Register(p)
+	registry.SetWarningOutput(io.Discard)
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.DataDir = t.TempDir()
+	cfg.Subscriptions = []provider.SubscriptionEntry{{Provider: p.ID(), Name: "sub-a"}}
+	cfg.Notifiers = []notify.Config{{Name: "hook", Type: "webhook", URL: hook.URL, Events: kinds}}
+
+	return NewServer(registry, cfg, "127.0.0.1:0"), events
+}
+
+// delivered waits for the dispatched notifications and returns them.
+func delivered(s *Server, events chan notify.Event) []notify.Event {
+	s.notifier.Wait()
+	close(events)
+	var got []notify.Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	return got
+}
+
+func TestRefresh_NotifiesStatusChange(t *testing.T) {
+	p := &flakyProvider{}
+	s, events := newNotifyingServer(t, p, nil)
+	sub := s.config.Subscriptions[0]
+
+	s.refresh(sub)
+	p.fail.Store(true)
+	s.refresh(sub)
+	s.refresh(sub)
+
+	got := delivered(s, events)
+	if len(got) != 1 {
+		t.Fatalf("expected one status event, got %+v", got)
+	}
+	if ev := got[0]; ev.Kind != notify.KindStatus || ev.PreviousStatus != provider.StatusOK || ev.Snapshot.Status != provider.StatusUnauthorized {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+// resettingProvider reports used values in turn, dropping back after the
+// second fetch as if the window reset.
+type resettingProvider struct {
+	countingProvider
+}
+
+func (p *resettingProvider) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*provider.UsageSnapshot, error) {
+	used := []float64{80, 90, 5}[min(int(p.calls.Add(1)), 3)-1]
+	return &provider.UsageSnapshot{
+		ProviderID: p.ID(),
+		Timestamp:  time.Now(),
+		Status:     provider.StatusOK,
+		Metrics: []provider.UsageMetric{{
+			Name:   "5h",
+			Amount: provider.UsageAmount{Used: provider.Ptr(used), Limit: provider.Ptr(100.0)},
+		}},
+	}, nil
+}
+
+func TestRefresh_NotifiesReset(t *testing.T) {
+	s, events := newNotifyingServer(t, &resettingProvider{}, []notify.Kind{notify.KindReset})
+	for range 3 {
+		s.refresh(s.config.Subscriptions[0])
+	}
+
+	got := delivered(s, events)
+	if len(got) != 1 {
+		t.Fatalf("expected one reset event, got %+v", got)
+	}
+	if ev := got[0]; ev.Kind != notify.KindReset || ev.MetricName != "5h" || ev.Subscription != "sub-a" {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestServer_UsageURL(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Settings.DataDir = t.TempDir()
+
+	tests := map[string]string{
+		"":                             "",
+		"https://sub-mon.example.com":  "https://sub-mon.example.com/api/v1/usage?name=my+kimi",
+		"http://nas.lan:3456/sub-mon/": "http://nas.lan:3456/sub-mon/api/v1/usage?name=my+kimi",
+	}
+	for base, want := range tests {
+		cfg.Settings.PublicURL = base
+		s := NewServer(provider.NewRegistry(), cfg, ":3456")
+		if got := s.usageURL("my kimi"); got != want {
+			t.Errorf("%q: got %q, want %q", base, got, want)
+		}
+	}
+}
@@ -1,39 +1,125 @@
 package api
 
 import (
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/user/subscriptions-monitor/internal/provider"
+	"github.com/user/subscriptions-monitor/internal/store"
 )
 
+type cacheEntry struct {
+	snapshot  provider.UsageSnapshot
+	fetchedAt time.Time
+	lastGood  *provider.UsageSnapshot
+}
+
+// Cache holds the latest snapshot of every subscription, keyed by
+// subscription name. Each entry ages independently so that a slow or failing
+// provider does not invalidate the others.
 type Cache struct {
-	mu        sync.RWMutex
-	data      []provider.UsageSnapshot
-	updatedAt time.Time
-	ttl       time.Duration
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+	ttl     time.Duration
+	ttls    map[string]time.Duration
 }
 
 func NewCache(ttl time.Duration) *Cache {
 	return &Cache{
-		ttl: ttl,
+		entries: make(map[string]*cacheEntry),
+		ttl:     ttl,
+		ttls:    make(map[string]time.Duration),
 	}
 }
 
-func (c *Cache) Get() ([]provider.UsageSnapshot, bool) {
+// SetTTL overrides the default TTL for a single subscription.
+func (c *Cache) SetTTL(name string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttls[name] = ttl
+}
+
+// Get returns the cached snapshot for a subscription together with its age.
+// fresh reports whether the entry is still within the TTL; stale entries are
+// still returned so callers can serve them while revalidating.
+func (c *Cache) Get(name string) (snap provider.UsageSnapshot, age time.Duration, fresh bool, ok bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if c.data == nil || time.Since(c.updatedAt) > c.ttl {
-		return nil, false
+	e, ok := c.entries[name]
+	if !ok {
+		return provider.UsageSnapshot{}, 0, false, false
+	}
+
+	ttl := c.ttl
+	if t, ok := c.ttls[name]; ok {
+		ttl = t
+	}
+
+	age = time.Since(e.fetchedAt)
+	return e.snapshot, age, age <= ttl, true
+}
+
+// Set stores snap under its subscription name and returns the snapshot that
+// will be served. A failed snapshot is replaced by the last successful one,
+// marked stale, when there is one.
+func (c *Cache) Set(snap provider.UsageSnapshot) provider.UsageSnapshot {
+	now := time.Now()
+	snap.FetchedAt = &now
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[snap.Name]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[snap.Name] = entry
 	}
-	return c.data, true
+
+	if snap.Status == provider.StatusOK {
+		good := snap
+		entry.lastGood = &good
+	}
+
+	entry.snapshot = provider.WithLastKnownGood(snap, entry.lastGood)
+	entry.fetchedAt = now
+	return entry.snapshot
 }
 
-func (c *Cache) Set(data []provider.UsageSnapshot) {
+// Restore seeds the cache with persisted entries. Each entry keeps the age it
+// had when it was saved, so that old data is revalidated right away.
+func (c *Cache) Restore(entries []store.Entry) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data = data
-	c.updatedAt = time.Now()
+	for _, e := range entries {
+		var fetchedAt time.Time
+		if e.Snapshot.FetchedAt != nil {
+			fetchedAt = *e.Snapshot.FetchedAt
+		}
+		c.entries[e.Snapshot.Name] = &cacheEntry{
+			snapshot:  e.Snapshot,
+			fetchedAt: fetchedAt,
+			lastGood:  e.LastGood,
+		}
+	}
+}
+
+// Export returns the cache content in a form suitable for persisting.
+func (c *Cache) Export() []store.Entry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]store.Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, store.Entry{
+			Snapshot: e.snapshot,
+			LastGood: e.lastGood,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Snapshot.Name < entries[j].Snapshot.Name
+	})
+	return entries
 }
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/notify"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// startDigests sends the digests of the notifiers that have a schedule.
+// Digests that fall due while the server is down are not sent afterwards.
+func (s *Server) startDigests() {
+	for _, d := range s.notifier.Digests() {
+		s.loops.Add(1)
+		go func(d notify.ScheduledDigest) {
+			defer s.loops.Done()
+			for {
+				next := d.Config.Next(time.Now())
+				timer := time.NewTimer(time.Until(next))
+				select {
+				case <-timer.C:
+					s.sendDigest(d, next)
+				case <-s.stopChan:
+					timer.Stop()
+					return
+				}
+			}
+		}(d)
+	}
+}
+
+func (s *Server) sendDigest(d notify.ScheduledDigest, at time.Time) {
+	digest := s.buildDigest(d.Config.Schedule, d.Config.Period(at))
+	if err := d.Notifier.SendDigest(context.Background(), digest); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: notifier %q failed to send digest: %v\n", d.Name, err)
+	}
+}
+
+// buildDigest collects the latest snapshot of every subscription, the quota
+// cycles overlapping the period and the costs over the period.
+func (s *Server) buildDigest(schedule string, period provider.TimePeriod) notify.Digest {
+	snaps := make([]provider.UsageSnapshot, 0, len(s.config.Subscriptions))
+	for _, sub := range s.config.Subscriptions {
+		snap, _, _, ok := s.cache.Get(sub.Name)
+		if !ok {
+			snap = s.refresh(sub)
+		}
+		snaps = append(snaps, snap)
+	}
+
+	var cycles []history.Cycle
+	if s.history != nil {
+		var err error
+		cycles, err = s.history.Cycles(history.Query{Since: period.Start, Until: period.End})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read quota cycles: %v\n", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Settings.Timeout)
+	defer cancel()
+	costs := s.registry.FetchCosts(ctx, s.config.Subscriptions, period)
+
+	return notify.NewDigest(schedule, period, snaps, cycles, costs)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/notify"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestBuildDigest(t *testing.T) {
+	p := &countingProvider{}
+	s := newTestServer(t, p)
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	f := func(v float64) *float64 { return &v }
+	resetsAt := now.Add(time.Hour)
+	if err := s.history.AppendSamples([]history.Sample{
+		{Time: now.Add(-2 * time.Hour), Subscription: "sub-a", Provider: p.ID(), Metric: "5h", Used: f(30), Limit: f(100), ResetsAt: &resetsAt},
+		{Time: now.Add(-time.Hour), Subscription: "sub-a", Provider: p.ID(), Metric: "5h", Used: f(70), Limit: f(100), ResetsAt: &resetsAt},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := s.buildDigest(notify.DigestDaily, provider.TimePeriod{Start: now.AddDate(0, 0, -1), End: now})
+	if n := p.calls.Load(); n != 1 {
+		t.Errorf("expected the uncached subscription to be fetched once, got %d", n)
+	}
+	if len(d.Subscriptions) != 1 {
+		t.Fatalf("expected one subscription, got %+v", d.Subscriptions)
+	}
+
+	e := d.Subscriptions[0]
+	if e.Snapshot.Name != "sub-a" || e.Snapshot.Status != provider.StatusOK || e.Cost != nil {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if len(e.Windows) != 1 || e.Windows[0].Metric != "5h" || e.Windows[0].Peak != 70 {
+		t.Errorf("unexpected windows: %+v", e.Windows)
+	}
+}
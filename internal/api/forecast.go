@@ -0,0 +1,81 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+const defaultForecastWindow = time.Hour
+
+// burnTracker keeps a rolling window of used values per subscription metric
+// across refreshes and annotates fresh snapshots with forecasts.
+type burnTracker struct {
+	mu     sync.Mutex
+	window time.Duration
+	points map[string][]provider.UsagePoint
+}
+
+func newBurnTracker(window time.Duration) *burnTracker {
+	if window <= 0 {
+		window = defaultForecastWindow
+	}
+	return &burnTracker{
+		window: window,
+		points: make(map[string][]provider.UsagePoint),
+	}
+}
+
+func burnKey(subscription, metric string) string {
+	return subscription + "\x00" + metric
+}
+
+// seed fills the window from the usage history, so that forecasts are
+// available right after a restart.
+func (t *burnTracker) seed(h *history.Store, now time.Time) error {
+	samples, err := h.Query(history.Query{Since: now.Add(-t.window)})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sample := range samples {
+		if sample.Used == nil {
+			continue
+		}
+		key := burnKey(sample.Subscription, sample.Metric)
+		t.points[key] = append(t.points[key], provider.UsagePoint{Time: sample.Time, Used: *sample.Used})
+	}
+	return nil
+}
+
+// observe sets the forecast of every metric of a successful snapshot from
+// the earlier observations and then records the snapshot's values.
+func (t *burnTracker) observe(snap *provider.UsageSnapshot, now time.Time) {
+	if snap.Status != provider.StatusOK || snap.Stale {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-t.window)
+	for i := range snap.Metrics {
+		m := &snap.Metrics[i]
+		key := burnKey(snap.Name, m.Name)
+
+		points := t.points[key]
+		for len(points) > 0 && points[0].Time.Before(cutoff) {
+			points = points[1:]
+		}
+
+		m.Forecast = provider.ForecastMetric(*m, points, now)
+		if m.Amount.Used != nil {
+			points = append(points, provider.UsagePoint{Time: now, Used: *m.Amount.Used})
+		}
+		t.points[key] = points
+	}
+}
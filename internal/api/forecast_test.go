@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestBurnTracker_ObserveAcrossRefreshes(t *testing.T) {
+	tracker := newBurnTracker(time.Hour)
+	base := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	resetsAt := base.Add(5 * time.Hour)
+
+	snap := func(used float64) *provider.UsageSnapshot {
+		return &provider.UsageSnapshot{
+			Name:   "sub-a",
+			Status: provider.StatusOK,
+			Metrics: []provider.UsageMetric{{
+				Name:   "5h Flows",
+				Window: provider.UsageWindow{ResetsAt: &resetsAt},
+				Amount: provider.UsageAmount{Used: provider.Ptr(used), Limit: provider.Ptr(200.0)},
+			}},
+		}
+	}
+
+	first := snap(100)
+	tracker.observe(first, base)
+	if first.Metrics[0].Forecast != nil {
+		t.Errorf("expected no forecast on first observation, got %+v", first.Metrics[0].Forecast)
+	}
+
+	second := snap(150)
+	tracker.observe(second, base.Add(30*time.Minute))
+	f := second.Metrics[0].Forecast
+	if f == nil || f.BurnRate != 100 || !f.ExhaustsBeforeReset {
+		t.Fatalf("expected burn rate 100/h exhausting before reset, got %+v", f)
+	}
+	if !f.ExhaustsAt.Equal(base.Add(time.Hour)) {
+		t.Errorf("expected exhaustion at %v, got %v", base.Add(time.Hour), f.ExhaustsAt)
+	}
+
+	// Observations older than the window no longer count.
+	third := snap(150)
+	tracker.observe(third, base.Add(80*time.Minute))
+	if f := third.Metrics[0].Forecast; f == nil || f.BurnRate != 0 {
+		t.Errorf("expected idle forecast from the window only, got %+v", f)
+	}
+}
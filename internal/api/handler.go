@@ -3,11 +3,25 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/history"
 	"github.com/user/subscriptions-monitor/internal/provider"
 )
 
+const (
+	defaultHistorySince  = "24h"
+	defaultCyclesSince   = "30d"
+	defaultHistoryPoints = 200
+	maxHistoryPoints     = 10000
+)
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -17,49 +31,263 @@ func (s *Server) usageHandler(w http.ResponseWriter, r *http.Request) {
 	providerFilter := r.URL.Query().Get("provider")
 	nameFilter := r.URL.Query().Get("name")
 
-	if data, ok := s.cache.Get(); ok {
-		filtered := s.filterSnapshots(data, providerFilter, nameFilter)
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache", "HIT")
-		json.NewEncoder(w).Encode(filtered)
-		return
-	}
+	filteredSubs := s.filterSubscriptions(providerFilter, nameFilter)
 
-	var filteredSubs []provider.SubscriptionEntry
-	for _, sub := range s.config.Subscriptions {
-		if providerFilter != "" && sub.Provider != providerFilter {
+	snapshots := make([]provider.UsageSnapshot, len(filteredSubs))
+	ages := make([]string, len(filteredSubs))
+	var maxAge time.Duration
+	anyStale, anyMiss := false, false
+
+	var wg sync.WaitGroup
+	for i, sub := range filteredSubs {
+		snap, age, fresh, ok := s.cache.Get(sub.Name)
+		if !ok {
+			anyMiss = true
+			wg.Add(1)
+			go func(idx int, e provider.SubscriptionEntry) {
+				defer wg.Done()
+				snapshots[idx] = s.refresh(e)
+			}(i, sub)
+			ages[i] = fmt.Sprintf("%s=0", sub.Name)
 			continue
 		}
-		if nameFilter != "" && sub.Name != nameFilter {
-			continue
+
+		if !fresh {
+			anyStale = true
+			s.revalidate(sub)
+		}
+
+		snapshots[i] = snap
+		ages[i] = fmt.Sprintf("%s=%d", sub.Name, int(age.Seconds()))
+		if age > maxAge {
+			maxAge = age
 		}
-		filteredSubs = append(filteredSubs, sub)
+	}
+	wg.Wait()
+
+	cacheStatus := "HIT"
+	switch {
+	case anyMiss:
+		cacheStatus = "MISS"
+	case anyStale:
+		cacheStatus = "STALE"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Header().Set("Age", strconv.Itoa(int(maxAge.Seconds())))
+	if len(ages) > 0 {
+		w.Header().Set("X-Cache-Age", strings.Join(ages, ", "))
+	}
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+func (s *Server) costsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	period, err := provider.ParsePeriod(query.Get("start"), query.Get("end"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
+	filteredSubs := s.filterSubscriptions(query.Get("provider"), query.Get("name"))
+
 	ctx, cancel := context.WithTimeout(r.Context(), s.config.Settings.Timeout)
 	defer cancel()
 
-	snapshots := s.registry.FetchAll(ctx, filteredSubs)
+	results := s.registry.FetchCosts(ctx, filteredSubs, period)
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
-	json.NewEncoder(w).Encode(snapshots)
+	json.NewEncoder(w).Encode(results)
+}
+
+type historyResponse struct {
+	Since  time.Time       `json:"since"`
+	Until  time.Time       `json:"until"`
+	Step   string          `json:"step"`
+	Series []historySeries `json:"series"`
+}
+
+type historySeries struct {
+	Subscription string           `json:"subscription"`
+	Provider     string           `json:"provider"`
+	Metric       string           `json:"metric"`
+	Points       []history.Bucket `json:"points"`
+	Resets       []history.Reset  `json:"resets"`
+}
+
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		writeError(w, http.StatusServiceUnavailable, "usage history is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+
+	sinceParam := query.Get("since")
+	if sinceParam == "" {
+		sinceParam = defaultHistorySince
+	}
+	since, err := history.ParseTime(sinceParam, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	until, err := history.ParseTime(query.Get("until"), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if until.IsZero() {
+		until = now
+	}
+	if !since.Before(until) {
+		writeError(w, http.StatusBadRequest, "since must be before until")
+		return
+	}
+
+	span := until.Sub(since)
+	step := history.StepFor(span, defaultHistoryPoints)
+	if p := query.Get("step"); p != "" {
+		step, err = history.ParseDuration(p)
+		if err != nil || step <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid step %q", p))
+			return
+		}
+		if span/step > maxHistoryPoints {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("step %s yields more than %d points", step, maxHistoryPoints))
+			return
+		}
+	}
+
+	samples, err := s.history.Query(history.Query{
+		Subscription: query.Get("name"),
+		Metric:       query.Get("metric"),
+		Since:        since,
+		Until:        until,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := historyResponse{
+		Since:  since,
+		Until:  until,
+		Step:   step.String(),
+		Series: []historySeries{},
+	}
+	for _, series := range history.GroupSeries(samples) {
+		resp.Series = append(resp.Series, historySeries{
+			Subscription: series.Subscription,
+			Provider:     series.Provider,
+			Metric:       series.Metric,
+			Points:       history.Aggregate(series.Samples, step),
+			Resets:       history.Resets(series.Samples),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type cyclesResponse struct {
+	Cycles  []history.Cycle        `json:"cycles"`
+	Summary []history.CycleSummary `json:"summary"`
+}
+
+func (s *Server) cyclesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.history == nil {
+		writeError(w, http.StatusServiceUnavailable, "usage history is disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+
+	sinceParam := query.Get("since")
+	if sinceParam == "" {
+		sinceParam = defaultCyclesSince
+	}
+	since, err := history.ParseTime(sinceParam, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	until, err := history.ParseTime(query.Get("until"), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cycles, err := s.history.Cycles(history.Query{
+		Subscription: query.Get("name"),
+		Metric:       query.Get("metric"),
+		Since:        since,
+		Until:        until,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	resp := cyclesResponse{
+		Cycles:  cycles,
+		Summary: history.Summarize(cycles),
+	}
+	if resp.Cycles == nil {
+		resp.Cycles = []history.Cycle{}
+	}
+	if resp.Summary == nil {
+		resp.Summary = []history.CycleSummary{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
-func (s *Server) filterSnapshots(snapshots []provider.UsageSnapshot, providerFilter, nameFilter string) []provider.UsageSnapshot {
-	if providerFilter == "" && nameFilter == "" {
-		return snapshots
+// alertsHandler lists the pending and firing alerts, optionally filtered by
+// state and subscription name.
+func (s *Server) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	stateFilter := alert.State(r.URL.Query().Get("state"))
+	nameFilter := r.URL.Query().Get("name")
+
+	switch stateFilter {
+	case "", alert.StatePending, alert.StateFiring:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid state %q, expected pending or firing", stateFilter))
+		return
 	}
 
-	var filtered []provider.UsageSnapshot
-	for _, snap := range snapshots {
-		if providerFilter != "" && snap.ProviderID != providerFilter {
+	alerts := []alert.Alert{}
+	if s.alerts != nil {
+		for _, a := range s.alerts.Active() {
+			if stateFilter != "" && a.State != stateFilter {
+				continue
+			}
+			if nameFilter != "" && a.Subscription != nameFilter {
+				continue
+			}
+			alerts = append(alerts, a)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+func (s *Server) filterSubscriptions(providerFilter, nameFilter string) []provider.SubscriptionEntry {
+	var filtered []provider.SubscriptionEntry
+	for _, sub := range s.config.Subscriptions {
+		if providerFilter != "" && sub.Provider != providerFilter {
 			continue
 		}
-		if nameFilter != "" && snap.Name != nameFilter {
+		if nameFilter != "" && sub.Name != nameFilter {
 			continue
 		}
-		filtered = append(filtered, snap)
+		filtered = append(filtered, sub)
 	}
 	return filtered
 }
@@ -80,8 +308,18 @@ func (s *Server) providersHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(providerInfo)
 }
 
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
 func (s *Server) registerHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/api/v1/health", s.healthHandler)
 	mux.HandleFunc("/api/v1/usage", s.usageHandler)
 	mux.HandleFunc("/api/v1/providers", s.providersHandler)
+	mux.HandleFunc("/api/v1/costs", s.costsHandler)
+	mux.HandleFunc("/api/v1/history", s.historyHandler)
+	mux.HandleFunc("/api/v1/cycles", s.cyclesHandler)
+	mux.HandleFunc("/api/v1/alerts", s.alertsHandler)
 }
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/history"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	s := newTestServer(t, &countingProvider{})
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	f := func(v float64) *float64 { return &v }
+	resetsAt := now.Add(-90 * time.Minute)
+	nextReset := now.Add(4 * time.Hour)
+	samples := []history.Sample{
+		{Time: now.Add(-3 * time.Hour), Subscription: "sub-a", Metric: "5h", Used: f(10), Limit: f(100), ResetsAt: &resetsAt},
+		{Time: now.Add(-3*time.Hour + 20*time.Minute), Subscription: "sub-a", Metric: "5h", Used: f(60), Limit: f(100), ResetsAt: &resetsAt},
+		{Time: now.Add(-time.Hour), Subscription: "sub-a", Metric: "5h", Used: f(5), Limit: f(100), ResetsAt: &nextReset},
+		{Time: now.Add(-time.Hour), Subscription: "sub-b", Metric: "5h", Used: f(1)},
+	}
+	if err := s.history.AppendSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.historyHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history?name=sub-a&since=6h&step=1h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp historyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Step != "1h0m0s" || len(resp.Series) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	series := resp.Series[0]
+	if len(series.Points) != 2 {
+		t.Fatalf("expected 2 points, got %+v", series.Points)
+	}
+	if p := series.Points[0]; p.Min != 10 || p.Max != 60 || p.Last != 60 {
+		t.Errorf("unexpected first point: %+v", p)
+	}
+	if len(series.Resets) != 1 || !series.Resets[0].Time.Equal(resetsAt) {
+		t.Errorf("expected reset at %v, got %+v", resetsAt, series.Resets)
+	}
+}
+
+func TestHistoryHandler_BadRequest(t *testing.T) {
+	s := newTestServer(t, &countingProvider{})
+
+	for _, q := range []string{"since=later", "step=0", "since=30d&step=1s", "since=1h&until=2h"} {
+		rec := httptest.NewRecorder()
+		s.historyHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history?"+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestCyclesHandler(t *testing.T) {
+	s := newTestServer(t, &countingProvider{})
+
+	now := time.Now().UTC().Truncate(time.Hour)
+	f := func(v float64) *float64 { return &v }
+	reset := now.Add(-2 * time.Hour)
+	next := now.Add(3 * time.Hour)
+	samples := []history.Sample{
+		{Time: now.Add(-4 * time.Hour), Subscription: "sub-a", Metric: "5h", Used: f(50), Limit: f(100), ResetsAt: &reset},
+		{Time: now.Add(-3 * time.Hour), Subscription: "sub-a", Metric: "5h", Used: f(100), Limit: f(100), ResetsAt: &reset},
+		{Time: now.Add(-time.Hour), Subscription: "sub-a", Metric: "5h", Used: f(10), Limit: f(100), ResetsAt: &next},
+	}
+	if err := s.history.AppendSamples(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.cyclesHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/cycles?name=sub-a", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp cyclesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Cycles) != 2 || !resp.Cycles[0].LimitHit || resp.Cycles[0].Blocked() != time.Hour {
+		t.Errorf("unexpected cycles: %+v", resp.Cycles)
+	}
+	if len(resp.Summary) != 1 || resp.Summary[0].LimitHits != 1 || resp.Summary[0].HitRate != 1 {
+		t.Errorf("unexpected summary: %+v", resp.Summary)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+const (
+	defaultRefreshInterval = 60 * time.Second
+	defaultCacheTTL        = 90 * time.Second
+)
+
+// startScheduler runs one refresh loop per subscription so that each account
+// is polled on its own interval.
+func (s *Server) startScheduler() {
+	for _, sub := range s.config.Subscriptions {
+		s.loops.Add(1)
+		go func() {
+			defer s.loops.Done()
+			s.scheduleLoop(sub, s.initialDelay(sub))
+		}()
+	}
+}
+
+func (s *Server) scheduleLoop(e provider.SubscriptionEntry, delay time.Duration) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var prev *provider.UsageSnapshot
+	last := s.refreshInterval(e)
+
+	for {
+		select {
+		case <-timer.C:
+			snap := s.refresh(e)
+			last = s.nextDelay(e, snap, prev, last)
+			prev = &snap
+			timer.Reset(last + s.jitter())
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// initialDelay lets a subscription whose restored cache entry is still
+// within its interval wait for the remainder instead of refetching at boot.
+func (s *Server) initialDelay(e provider.SubscriptionEntry) time.Duration {
+	delay := s.jitter()
+	if _, age, _, ok := s.cache.Get(e.Name); ok {
+		if remaining := s.refreshInterval(e) - age; remaining > 0 {
+			delay += remaining
+		}
+	}
+	return delay
+}
+
+// nextDelay returns the delay before the next scheduled refresh, adapted to
+// the latest snapshot when adaptive polling is enabled and never shorter
+// than what the subscription's call budget allows.
+func (s *Server) nextDelay(e provider.SubscriptionEntry, snap provider.UsageSnapshot, prev *provider.UsageSnapshot, last time.Duration) time.Duration {
+	now := time.Now()
+
+	delay := s.refreshInterval(e)
+	if adaptive := s.config.Settings.Adaptive; adaptive.Enabled {
+		delay = adaptiveInterval(delay, adaptive, snap, prev, last, now)
+	}
+
+	if wait := s.registry.BudgetWait(e); wait > delay {
+		delay = wait
+	}
+	return delay
+}
+
+func (s *Server) refreshInterval(e provider.SubscriptionEntry) time.Duration {
+	if e.RefreshInterval > 0 {
+		return e.RefreshInterval
+	}
+	if s.config.Settings.RefreshInterval > 0 {
+		return s.config.Settings.RefreshInterval
+	}
+	return defaultRefreshInterval
+}
+
+func (s *Server) jitter() time.Duration {
+	j := s.config.Settings.RefreshJitter
+	if j <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(j)))
+}
+
+// cacheTTL is how long a subscription's entry is considered fresh: the
+// configured cache_ttl, or by default 1.5x the subscription's refresh interval
+// and at least defaultCacheTTL, so that requests do not trigger revalidations
+// more often than the schedule refreshes.
+func (s *Server) cacheTTL(e provider.SubscriptionEntry) time.Duration {
+	if ttl := s.config.Settings.CacheTTL; ttl > 0 {
+		return ttl
+	}
+	return max(defaultCacheTTL, s.refreshInterval(e)*3/2)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestScheduler_PerSubscriptionInterval(t *testing.T) {
+	fast := &countingProvider{}
+	slow := &slowCountingProvider{}
+
+	registry := provider.NewRegistry()
+	registry.Register(fast)
+	registry.Register(slow)
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.DataDir = t.TempDir()
+	cfg.Settings.RefreshInterval = time.Hour
+	cfg.Settings.RefreshJitter = 0
+	cfg.Subscriptions = []provider.SubscriptionEntry{
+		{Provider: fast.ID(), Name: "fast", RefreshInterval: 20 * time.Millisecond},
+		{Provider: slow.ID(), Name: "slow"},
+	}
+
+	s := NewServer(registry, cfg, "127.0.0.1:0")
+	s.startScheduler()
+	waitFor(t, func() bool { return fast.calls.Load() >= 3 && slow.calls.Load() >= 1 })
+	close(s.stopChan)
+	s.loops.Wait()
+
+	if n := slow.calls.Load(); n != 1 {
+		t.Errorf("expected slow subscription to be fetched once at startup, got %d", n)
+	}
+}
+
+func TestServer_ShutdownWaitsForRefresh(t *testing.T) {
+	p := &countingProvider{delay: 100 * time.Millisecond}
+	s := newTestServer(t, p)
+	s.config.Settings.RefreshJitter = 0
+
+	s.startScheduler()
+	waitFor(t, func() bool { return p.calls.Load() > 0 })
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.snapshotsPath()); err != nil {
+		t.Errorf("expected the refresh in progress to be persisted before Shutdown returns: %v", err)
+	}
+}
+
+func TestServer_CacheTTL(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Settings.Adaptive.Enabled = true
+	s := &Server{config: cfg}
+
+	if ttl := s.cacheTTL(provider.SubscriptionEntry{RefreshInterval: 15 * time.Minute}); ttl != 22*time.Minute+30*time.Second {
+		t.Errorf("expected the default TTL to follow a long interval, got %s", ttl)
+	}
+	if ttl := s.cacheTTL(provider.SubscriptionEntry{RefreshInterval: 30 * time.Second}); ttl != 90*time.Second {
+		t.Errorf("expected the default TTL for a short interval, got %s", ttl)
+	}
+
+	cfg.Settings.CacheTTL = 90 * time.Second
+	if ttl := s.cacheTTL(provider.SubscriptionEntry{RefreshInterval: 15 * time.Minute}); ttl != 90*time.Second {
+		t.Errorf("expected the configured TTL to be honoured, got %s", ttl)
+	}
+}
+
+type slowCountingProvider struct {
+	countingProvider
+}
+
+func (p *slowCountingProvider) ID() string { return "slow-counting" }
@@ -2,33 +2,66 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/user/subscriptions-monitor/internal/alert"
 	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/notify"
 	"github.com/user/subscriptions-monitor/internal/provider"
-)
-
-const (
-	cacheTTL       = 90 * time.Second
-	refreshInterval = 60 * time.Second
+	"github.com/user/subscriptions-monitor/internal/store"
 )
 
 type Server struct {
-	registry *provider.Registry
-	config   *config.Config
-	server   *http.Server
-	cache    *Cache
-	stopChan chan struct{}
+	registry  *provider.Registry
+	config    *config.Config
+	server    *http.Server
+	cache     *Cache
+	flights   flightGroup[provider.UsageSnapshot]
+	history   *history.Store
+	burn      *burnTracker
+	alerts    *alert.Engine
+	notifier  *notify.Dispatcher
+	dataDir   string
+	persistMu sync.Mutex
+	loops     sync.WaitGroup
+	stopChan  chan struct{}
 }
 
 func NewServer(registry *provider.Registry, cfg *config.Config, addr string) *Server {
 	s := &Server{
 		registry: registry,
 		config:   cfg,
-		cache:    NewCache(cacheTTL),
+		cache:    NewCache(defaultCacheTTL),
+		dataDir:  cfg.Settings.ResolveDataDir(),
+		burn:     newBurnTracker(cfg.Settings.ForecastWindow),
 		stopChan: make(chan struct{}),
 	}
+	s.notifier = notify.NewDispatcher(cfg.Notifiers, s.loadThreads())
+
+	for _, sub := range cfg.Subscriptions {
+		s.cache.SetTTL(sub.Name, s.cacheTTL(sub))
+	}
+
+	if cfg.Settings.History.Enabled {
+		h, err := history.Open(s.dataDir, cfg.Settings.History.Options())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: usage history disabled: %v\n", err)
+		} else {
+			s.history = h
+			if err := s.burn.seed(h, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to load recent history: %v\n", err)
+			}
+		}
+	}
+
+	s.loadAlerts()
 
 	mux := http.NewServeMux()
 	s.registerHandlers(mux)
@@ -41,39 +74,175 @@ func NewServer(registry *provider.Registry, cfg *config.Config, addr string) *Se
 	return s
 }
 
+// Start serves the persisted snapshots right away and revalidates them in
+// the background instead of blocking on a first refresh.
 func (s *Server) Start() error {
-	ctx := context.Background()
-	s.refreshCache(ctx)
-
-	go s.startBackgroundRefresh()
+	s.loadCache()
+	s.startScheduler()
+	s.startCompactor()
+	s.startDigests()
 
 	return s.server.ListenAndServe()
 }
 
+// Shutdown stops the background loops, waiting for a refresh or revalidation
+// in progress so that nothing is written to the data directory afterwards,
+// and for pending notifications.
 func (s *Server) Shutdown(ctx context.Context) error {
 	close(s.stopChan)
-	return s.server.Shutdown(ctx)
+	err := s.server.Shutdown(ctx)
+	s.loops.Wait()
+	s.notifier.Wait()
+	return err
 }
 
-func (s *Server) startBackgroundRefresh() {
-	ticker := time.NewTicker(refreshInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			ctx := context.Background()
-			s.refreshCache(ctx)
-		case <-s.stopChan:
-			return
+// refresh fetches a single subscription and stores the result in the cache.
+// Concurrent refreshes of the same subscription share one upstream fetch, and
+// once the subscription's hourly call budget is spent the cached snapshot is
+// returned instead; without one the registry reports the spent budget.
+// The fetch is detached from any request context so that a disconnecting
+// client does not cancel a refresh other callers are waiting on.
+func (s *Server) refresh(e provider.SubscriptionEntry) provider.UsageSnapshot {
+	return s.flights.Do(e.Name, func() provider.UsageSnapshot {
+		if s.registry.BudgetWait(e) > 0 {
+			if snap, _, _, cached := s.cache.Get(e.Name); cached {
+				return snap
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Settings.Timeout)
+		defer cancel()
+
+		snap := s.registry.FetchAll(ctx, []provider.SubscriptionEntry{e})[0]
+		s.burn.observe(&snap, time.Now())
+		s.recordHistory(snap)
+		prev, _, _, cached := s.cache.Get(e.Name)
+		snap = s.cache.Set(snap)
+		s.persistCache()
+		if cached {
+			now := time.Now()
+			if prev.Status != snap.Status {
+				s.dispatch(notify.StatusEvent(prev.Status, snap, now))
+			}
+			for _, ev := range notify.ResetEvents(prev, snap, now) {
+				s.dispatch(ev)
+			}
 		}
+		s.evaluateAlerts(snap)
+		return snap
+	})
+}
+
+// revalidate refreshes e in the background. Shutdown waits for it like for
+// the scheduled refreshes, and none is started once the server stops.
+func (s *Server) revalidate(e provider.SubscriptionEntry) {
+	select {
+	case <-s.stopChan:
+		return
+	default:
 	}
+
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		s.refresh(e)
+	}()
 }
 
-func (s *Server) refreshCache(ctx context.Context) {
-	ctx, cancel := context.WithTimeout(ctx, s.config.Settings.Timeout)
-	defer cancel()
+// dispatch links ev to its subscription on this server and notifies it.
+func (s *Server) dispatch(ev notify.Event) {
+	ev.URL = s.usageURL(ev.Subscription)
+	s.notifier.Dispatch(ev)
+}
 
-	snapshots := s.registry.FetchAll(ctx, s.config.Subscriptions)
-	s.cache.Set(snapshots)
+// usageURL is the usage endpoint of a subscription under settings.public_url,
+// or empty without one: the listen address is rarely reachable from where
+// notifications are read.
+func (s *Server) usageURL(name string) string {
+	base := s.config.Settings.PublicURL
+	if base == "" {
+		return ""
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	u = u.JoinPath("api", "v1", "usage")
+	u.RawQuery = url.Values{"name": {name}}.Encode()
+	return u.String()
+}
+
+func (s *Server) snapshotsPath() string {
+	return filepath.Join(s.dataDir, store.SnapshotsFile)
+}
+
+func (s *Server) loadCache() {
+	entries, err := store.LoadSnapshots(s.snapshotsPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load cached snapshots: %v\n", err)
+		return
+	}
+
+	configured := make(map[string]bool, len(s.config.Subscriptions))
+	for _, sub := range s.config.Subscriptions {
+		configured[sub.Name] = true
+	}
+
+	var restored []store.Entry
+	for _, e := range entries {
+		if configured[e.Snapshot.Name] {
+			restored = append(restored, e)
+		}
+	}
+	s.cache.Restore(restored)
+}
+
+func (s *Server) persistCache() {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+
+	if err := store.SaveSnapshots(s.snapshotsPath(), s.cache.Export()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to persist snapshots: %v\n", err)
+	}
+}
+
+// recordHistory appends a freshly fetched snapshot to the usage history. It
+// must see the snapshot before the cache substitutes last-known-good values.
+func (s *Server) recordHistory(snap provider.UsageSnapshot) {
+	if s.history == nil {
+		return
+	}
+	if err := s.history.Append(snap); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", err)
+	}
+}
+
+// startCompactor applies history retention and downsampling at startup and
+// then once an hour.
+func (s *Server) startCompactor() {
+	if s.history == nil {
+		return
+	}
+
+	compact := func() {
+		if err := s.history.Compact(time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to compact history: %v\n", err)
+		}
+	}
+
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		compact()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				compact()
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// waitFor polls cond until it holds, failing the test after a generous
+// deadline so that a loaded machine does not make it flaky.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before the deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type countingProvider struct {
+	calls atomic.Int32
+	delay time.Duration
+}
+
+func (p *countingProvider) ID() string          { return "counting" }
+func (p *countingProvider) DisplayName() string { return "Counting" }
+func (p *countingProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{SupportsUsageMetrics: true}
+}
+func (p *countingProvider) ValidateAuth(ctx context.Context, auth provider.AuthConfig) error {
+	return nil
+}
+func (p *countingProvider) FetchUsage(ctx context.Context, auth provider.AuthConfig) (*provider.UsageSnapshot, error) {
+	p.calls.Add(1)
+	time.Sleep(p.delay)
+	return &provider.UsageSnapshot{
+		ProviderID: p.ID(),
+		Timestamp:  time.Now(),
+		Metrics:    []provider.UsageMetric{},
+		Status:     provider.StatusOK,
+	}, nil
+}
+
+func newTestServer(t *testing.T, p provider.Provider) *Server {
+	t.Helper()
+
+	registry := provider.NewRegistry()
+	if err := registry.Register(p); err != nil {
+		t.Fatalf("register provider: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.DataDir = t.TempDir()
+	cfg.Subscriptions = []provider.SubscriptionEntry{{Provider: p.ID(), Name: "sub-a"}}
+
+	return NewServer(registry, cfg, "127.0.0.1:0")
+}
+
+func getUsage(t *testing.T, s *Server) (*httptest.ResponseRecorder, []provider.UsageSnapshot) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	s.usageHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/usage", nil))
+
+	var snaps []provider.UsageSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&snaps); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return rec, snaps
+}
+
+func TestUsageHandler_MissThenHit(t *testing.T) {
+	p := &countingProvider{}
+	s := newTestServer(t, p)
+
+	rec, snaps := getUsage(t, s)
+	if rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected MISS on cold cache, got %q", rec.Header().Get("X-Cache"))
+	}
+	if len(snaps) != 1 || snaps[0].FetchedAt == nil {
+		t.Fatalf("expected one snapshot with fetched_at, got %+v", snaps)
+	}
+
+	rec, _ = getUsage(t, s)
+	if rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected HIT on warm cache, got %q", rec.Header().Get("X-Cache"))
+	}
+	if rec.Header().Get("Age") == "" || rec.Header().Get("X-Cache-Age") == "" {
+		t.Error("expected Age and X-Cache-Age headers")
+	}
+	if p.calls.Load() != 1 {
+		t.Errorf("expected a single upstream fetch, got %d", p.calls.Load())
+	}
+}
+
+func TestUsageHandler_ServesStaleWhileRevalidating(t *testing.T) {
+	p := &countingProvider{}
+	s := newTestServer(t, p)
+	s.cache.SetTTL("sub-a", 0)
+
+	getUsage(t, s)
+	rec, snaps := getUsage(t, s)
+	if rec.Header().Get("X-Cache") != "STALE" {
+		t.Errorf("expected STALE, got %q", rec.Header().Get("X-Cache"))
+	}
+	if len(snaps) != 1 || snaps[0].Name != "sub-a" {
+		t.Fatalf("expected stale snapshot to be served, got %+v", snaps)
+	}
+
+	waitFor(t, func() bool { return p.calls.Load() >= 2 })
+	if p.calls.Load() != 2 {
+		t.Errorf("expected a background revalidation, got %d fetches", p.calls.Load())
+	}
+}
+
+func TestRefresh_DeduplicatesConcurrentCalls(t *testing.T) {
+	p := &countingProvider{delay: 50 * time.Millisecond}
+	s := newTestServer(t, p)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.refresh(s.config.Subscriptions[0])
+		}()
+	}
+	wg.Wait()
+
+	if p.calls.Load() != 1 {
+		t.Errorf("expected concurrent refreshes to share one fetch, got %d", p.calls.Load())
+	}
+}
+
+func TestServer_RestoresPersistedCache(t *testing.T) {
+	p := &countingProvider{}
+	s := newTestServer(t, p)
+	s.refresh(s.config.Subscriptions[0])
+
+	restarted := NewServer(s.registry, s.config, "127.0.0.1:0")
+	restarted.loadCache()
+
+	snap, _, _, ok := restarted.cache.Get("sub-a")
+	if !ok {
+		t.Fatal("expected persisted snapshot to be restored")
+	}
+	if snap.FetchedAt == nil {
+		t.Error("expected restored snapshot to keep fetched_at")
+	}
+
+	rec, _ := getUsage(t, restarted)
+	if rec.Header().Get("X-Cache") == "MISS" {
+		t.Error("expected restored snapshot to be served without a synchronous fetch")
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/subscriptions-monitor/internal/provider"
+	"github.com/user/subscriptions-monitor/internal/store"
+)
+
+func init() {
+	collectCmd.Flags().StringP("provider", "p", "", "Filter by provider ID")
+	collectCmd.Flags().StringP("name", "n", "", "Filter by subscription name")
+	collectCmd.Flags().Bool("quiet", false, "Only report failures")
+}
+
+var collectCmd = &cobra.Command{
+	Use:   "collect",
+	Short: "Fetch usage once and record it in the data directory",
+	Long: `Fetches usage data for all subscriptions once, appends the snapshots to
+<data_dir>/snapshot-log/<day>.jsonl (kept for history.retention), updates the
+last-known snapshots served by serve and records and compacts the usage
+history. Exits with a non-zero status when any subscription could not be
+fetched, which makes it suitable for cron or a systemd timer.
+
+Do not run it against the data directory of a running serve: serve overwrites
+<data_dir>/snapshots.json from its own cache after every refresh.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, registry, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+		// From here on errors are about the fetched data, not the invocation.
+		cmd.SilenceUsage = true
+
+		providerFilter, _ := cmd.Flags().GetString("provider")
+		nameFilter, _ := cmd.Flags().GetString("name")
+		quiet, _ := cmd.Flags().GetBool("quiet")
+
+		filteredSubs := filterSubscriptions(cfg.Subscriptions, providerFilter, nameFilter)
+		if len(filteredSubs) == 0 {
+			return fmt.Errorf("no subscriptions to collect")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Settings.Timeout)
+		defer cancel()
+
+		snapshots := registry.FetchAll(ctx, filteredSubs)
+		newHistoryForecaster(cfg).applyAll(snapshots)
+		now := time.Now()
+		for i := range snapshots {
+			snapshots[i].FetchedAt = provider.Ptr(now)
+		}
+
+		dataDir := cfg.Settings.ResolveDataDir()
+		logDir := filepath.Join(dataDir, store.SnapshotLogDir)
+		if err := store.AppendSnapshotLog(logDir, snapshots, now); err != nil {
+			return fmt.Errorf("failed to append snapshot log: %w", err)
+		}
+		if retention := cfg.Settings.History.Retention; retention > 0 {
+			if err := store.PruneSnapshotLog(logDir, now.Add(-retention)); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to prune snapshot log: %v\n", err)
+			}
+		}
+
+		snapshotsPath := filepath.Join(dataDir, store.SnapshotsFile)
+		entries, err := store.LoadSnapshots(snapshotsPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load cached snapshots: %v\n", err)
+		}
+		if err := store.SaveSnapshots(snapshotsPath, store.Update(entries, snapshots, now)); err != nil {
+			return fmt.Errorf("failed to update snapshots: %w", err)
+		}
+
+		recordHistory(cfg, snapshots)
+		compactHistory(cfg)
+
+		failed := 0
+		for _, snap := range snapshots {
+			if snap.Status != provider.StatusOK {
+				failed++
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", snap.Name, snap.Status, snap.Error)
+				continue
+			}
+			if !quiet {
+				fmt.Printf("%s: %s\n", snap.Name, snap.Status)
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d subscriptions failed", failed, len(snapshots))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func init() {
+	costsCmd.Flags().String("from", "", "Start of the period (YYYY-MM-DD or RFC3339, default 30 days before --to)")
+	costsCmd.Flags().String("to", "", "End of the period, inclusive for dates (YYYY-MM-DD or RFC3339, default now)")
+	costsCmd.Flags().BoolP("json", "j", false, "Output as JSON")
+	costsCmd.Flags().StringP("provider", "p", "", "Filter by provider ID")
+	costsCmd.Flags().StringP("name", "n", "", "Filter by subscription name")
+}
+
+var costsCmd = &cobra.Command{
+	Use:   "costs",
+	Short: "Query subscription costs",
+	Long:  `Fetches the cost breakdown (total and by model) for subscriptions whose provider supports it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+
+		period, err := provider.ParsePeriod(from, to, time.Now())
+		if err != nil {
+			return err
+		}
+
+		cfg, registry, err := setup(cmd)
+		if err != nil {
+			return err
+		}
+
+		providerFilter, _ := cmd.Flags().GetString("provider")
+		nameFilter, _ := cmd.Flags().GetString("name")
+
+		filteredSubs := filterSubscriptions(cfg.Subscriptions, providerFilter, nameFilter)
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Settings.Timeout)
+		defer cancel()
+
+		results := registry.FetchCosts(ctx, filteredSubs, period)
+
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		if jsonOutput {
+			return PrintJSON(results)
+		}
+
+		return PrintCostTable(results, period)
+	},
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/format"
+	"github.com/user/subscriptions-monitor/internal/history"
+)
+
+func init() {
+	cyclesCmd.Flags().StringP("name", "n", "", "Filter by subscription name")
+	cyclesCmd.Flags().StringP("metric", "m", "", "Filter by metric name")
+	cyclesCmd.Flags().String("since", "30d", "Start of the range (duration like 7d, YYYY-MM-DD or RFC3339)")
+	cyclesCmd.Flags().String("until", "", "End of the range (duration, YYYY-MM-DD or RFC3339, default now)")
+	cyclesCmd.Flags().BoolP("json", "j", false, "Output as JSON")
+}
+
+var cyclesCmd = &cobra.Command{
+	Use:   "cycles",
+	Short: "Show quota cycles and how often limits were hit",
+	Long: `Shows every quota window detected in the usage history with its peak usage,
+whether and when the limit was hit and how long the subscription was blocked.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		untilFlag, _ := cmd.Flags().GetString("until")
+		since, err := history.ParseTime(sinceFlag, now)
+		if err != nil {
+			return err
+		}
+		until, err := history.ParseTime(untilFlag, now)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		store, err := history.Open(cfg.Settings.ResolveDataDir(), cfg.Settings.History.Options())
+		if err != nil {
+			return err
+		}
+
+		name, _ := cmd.Flags().GetString("name")
+		metric, _ := cmd.Flags().GetString("metric")
+		cycles, err := store.Cycles(history.Query{
+			Subscription: name,
+			Metric:       metric,
+			Since:        since,
+			Until:        until,
+		})
+		if err != nil {
+			return err
+		}
+
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		if jsonOutput {
+			return PrintJSON(cyclesResult{Cycles: cycles, Summary: history.Summarize(cycles)})
+		}
+
+		if len(cycles) == 0 {
+			fmt.Println("No cycles recorded for this range.")
+			return nil
+		}
+		PrintCycleTable(cycles)
+		return nil
+	},
+}
+
+type cyclesResult struct {
+	Cycles  []history.Cycle        `json:"cycles"`
+	Summary []history.CycleSummary `json:"summary"`
+}
+
+func PrintCycleTable(cycles []history.Cycle) {
+	cellStyle := lipgloss.NewStyle().Padding(0, 1)
+
+	t := table.New().
+		Border(lipgloss.ASCIIBorder()).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			return cellStyle
+		}).
+		Headers("NAME", "METRIC", "CYCLE", "PEAK", "LIMIT HIT", "BLOCKED")
+
+	for _, c := range cycles {
+		t.Row(
+			c.Subscription,
+			c.Metric,
+			formatCycleRange(c),
+			formatCyclePeak(c),
+			formatCycleHit(c),
+			formatCycleBlocked(c),
+		)
+	}
+
+	fmt.Println("AI Subscriptions Quota Cycles")
+	fmt.Println(t)
+
+	for _, sum := range history.Summarize(cycles) {
+		fmt.Printf("%s / %s: limit hit in %d of %d cycles, blocked %s in total\n",
+			sum.Subscription, sum.Metric, sum.LimitHits, sum.Cycles,
+			format.Duration(time.Duration(sum.BlockedSeconds)*time.Second))
+	}
+}
+
+func formatCycleRange(c history.Cycle) string {
+	start := c.Start.Local().Format("01-02 15:04")
+	if !c.Complete {
+		return start + " - now"
+	}
+	return start + " - " + c.End.Local().Format("01-02 15:04")
+}
+
+func formatCyclePeak(c history.Cycle) string {
+	if c.Limit == nil || *c.Limit <= 0 {
+		return format.Number(c.Peak)
+	}
+	percent := c.Peak / *c.Limit * 100
+	return fmt.Sprintf("%s %.0f%%", format.ProgressBar(percent), percent)
+}
+
+func formatCycleHit(c history.Cycle) string {
+	if !c.LimitHit || c.HitAt == nil {
+		return "no"
+	}
+	return fmt.Sprintf("%s (after %s)", c.HitAt.Local().Format("01-02 15:04"), format.Duration(c.HitAt.Sub(c.Start)))
+}
+
+func formatCycleBlocked(c history.Cycle) string {
+	if c.BlockedSeconds == 0 {
+		return "-"
+	}
+	return format.Duration(c.Blocked())
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// historyForecaster sets forecasts on fresh snapshots from the usage recorded
+// by earlier runs, the CLI's equivalent of the rolling window kept by serve.
+type historyForecaster struct {
+	store  *history.Store
+	window time.Duration
+}
+
+// newHistoryForecaster returns nil when the history is disabled or cannot be
+// opened; a nil forecaster leaves snapshots untouched.
+func newHistoryForecaster(cfg *config.Config) *historyForecaster {
+	if !cfg.Settings.History.Enabled {
+		return nil
+	}
+	h, err := history.Open(cfg.Settings.ResolveDataDir(), cfg.Settings.History.Options())
+	if err != nil {
+		return nil
+	}
+	return &historyForecaster{store: h, window: cfg.Settings.ForecastWindow}
+}
+
+func (f *historyForecaster) apply(snap *provider.UsageSnapshot) {
+	if f == nil || snap.Status != provider.StatusOK || snap.Stale {
+		return
+	}
+
+	now := time.Now()
+	samples, err := f.store.Query(history.Query{Subscription: snap.Name, Since: now.Add(-f.window)})
+	if err != nil {
+		return
+	}
+
+	points := make(map[string][]provider.UsagePoint)
+	for _, sample := range samples {
+		if sample.Used != nil {
+			points[sample.Metric] = append(points[sample.Metric], provider.UsagePoint{Time: sample.Time, Used: *sample.Used})
+		}
+	}
+	for i := range snap.Metrics {
+		snap.Metrics[i].Forecast = provider.ForecastMetric(snap.Metrics[i], points[snap.Metrics[i].Name], now)
+	}
+}
+
+func (f *historyForecaster) applyAll(snapshots []provider.UsageSnapshot) {
+	for i := range snapshots {
+		f.apply(&snapshots[i])
+	}
+}
+
+// stream applies forecasts to results as they arrive.
+func (f *historyForecaster) stream(in <-chan provider.Result) <-chan provider.Result {
+	if f == nil {
+		return in
+	}
+	out := make(chan provider.Result, cap(in))
+	go func() {
+		defer close(out)
+		for res := range in {
+			f.apply(&res.Snapshot)
+			out <- res
+		}
+	}()
+	return out
+}
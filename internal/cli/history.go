@@ -0,0 +1,265 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spf13/cobra"
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/format"
+	"github.com/user/subscriptions-monitor/internal/history"
+)
+
+const (
+	historyTableRows = 24
+	sparklineWidth   = 60
+)
+
+var sparkRunes = []rune("▁▂▃▄▅▆▇█")
+
+func init() {
+	historyCmd.Flags().StringP("name", "n", "", "Filter by subscription name")
+	historyCmd.Flags().StringP("metric", "m", "", "Filter by metric name")
+	historyCmd.Flags().String("since", "24h", "Start of the range (duration like 7d, YYYY-MM-DD or RFC3339)")
+	historyCmd.Flags().String("until", "", "End of the range (duration, YYYY-MM-DD or RFC3339, default now)")
+	historyCmd.Flags().Duration("step", 0, "Aggregation interval (default: automatic for the table, raw samples for --json/--csv)")
+	historyCmd.Flags().BoolP("json", "j", false, "Output as JSON")
+	historyCmd.Flags().Bool("csv", false, "Output as CSV")
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recorded usage over time",
+	Long:  `Shows the usage history recorded by query, collect and serve as a table with a sparkline per metric.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		untilFlag, _ := cmd.Flags().GetString("until")
+		since, err := history.ParseTime(sinceFlag, now)
+		if err != nil {
+			return err
+		}
+		until, err := history.ParseTime(untilFlag, now)
+		if err != nil {
+			return err
+		}
+		if until.IsZero() {
+			until = now
+		}
+		if !since.IsZero() && !since.Before(until) {
+			return fmt.Errorf("--since must be before --until")
+		}
+
+		cfg, err := config.Load(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		store, err := history.Open(cfg.Settings.ResolveDataDir(), cfg.Settings.History.Options())
+		if err != nil {
+			return err
+		}
+
+		name, _ := cmd.Flags().GetString("name")
+		metric, _ := cmd.Flags().GetString("metric")
+		samples, err := store.Query(history.Query{
+			Subscription: name,
+			Metric:       metric,
+			Since:        since,
+			Until:        until,
+		})
+		if err != nil {
+			return err
+		}
+		series := history.GroupSeries(samples)
+
+		step, _ := cmd.Flags().GetDuration("step")
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		csvOutput, _ := cmd.Flags().GetBool("csv")
+		switch {
+		case jsonOutput:
+			return PrintJSON(historyJSON(series, step))
+		case csvOutput:
+			return printHistoryCSV(series, step)
+		}
+
+		if len(series) == 0 {
+			fmt.Println("No history recorded for this range.")
+			return nil
+		}
+
+		from := since
+		if from.IsZero() {
+			from = samples[0].Time
+		}
+		if step <= 0 {
+			step = history.StepFor(until.Sub(from), historyTableRows)
+		}
+		PrintHistoryTable(series, from, until, step)
+		return nil
+	},
+}
+
+type historySeriesJSON struct {
+	Subscription string           `json:"subscription"`
+	Provider     string           `json:"provider"`
+	Metric       string           `json:"metric"`
+	Step         string           `json:"step,omitempty"`
+	Samples      []history.Sample `json:"samples,omitempty"`
+	Buckets      []history.Bucket `json:"buckets,omitempty"`
+}
+
+func historyJSON(series []history.Series, step time.Duration) []historySeriesJSON {
+	out := make([]historySeriesJSON, 0, len(series))
+	for _, s := range series {
+		entry := historySeriesJSON{
+			Subscription: s.Subscription,
+			Provider:     s.Provider,
+			Metric:       s.Metric,
+		}
+		if step > 0 {
+			entry.Step = step.String()
+			entry.Buckets = history.Aggregate(s.Samples, step)
+		} else {
+			entry.Samples = s.Samples
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
+func printHistoryCSV(series []history.Series, step time.Duration) error {
+	w := csv.NewWriter(os.Stdout)
+
+	if step > 0 {
+		w.Write([]string{"time", "subscription", "metric", "min", "max", "last", "limit", "samples"})
+		for _, s := range series {
+			for _, b := range history.Aggregate(s.Samples, step) {
+				w.Write([]string{
+					b.Time.Format(time.RFC3339),
+					s.Subscription,
+					s.Metric,
+					formatCSVFloat(&b.Min),
+					formatCSVFloat(&b.Max),
+					formatCSVFloat(&b.Last),
+					formatCSVFloat(b.Limit),
+					strconv.Itoa(b.Samples),
+				})
+			}
+		}
+	} else {
+		w.Write([]string{"time", "subscription", "metric", "window_id", "used", "limit", "resets_at"})
+		for _, s := range series {
+			for _, sample := range s.Samples {
+				resetsAt := ""
+				if sample.ResetsAt != nil {
+					resetsAt = sample.ResetsAt.Format(time.RFC3339)
+				}
+				w.Write([]string{
+					sample.Time.Format(time.RFC3339),
+					sample.Subscription,
+					sample.Metric,
+					sample.WindowID,
+					formatCSVFloat(sample.Used),
+					formatCSVFloat(sample.Limit),
+					resetsAt,
+				})
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func formatCSVFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func PrintHistoryTable(series []history.Series, from, until time.Time, step time.Duration) {
+	cellStyle := lipgloss.NewStyle().Padding(0, 1)
+
+	fmt.Printf("AI Subscriptions History (%s - %s, every %s)\n",
+		from.Format("2006-01-02 15:04"),
+		until.Format("2006-01-02 15:04"),
+		format.Duration(step),
+	)
+
+	for _, s := range series {
+		t := table.New().
+			Border(lipgloss.ASCIIBorder()).
+			StyleFunc(func(row, col int) lipgloss.Style {
+				return cellStyle
+			}).
+			Headers("TIME", "USED", "RANGE", "USAGE")
+
+		for _, b := range history.Aggregate(s.Samples, step) {
+			t.Row(
+				b.Time.Local().Format("01-02 15:04"),
+				format.Number(b.Last),
+				formatHistoryRange(b),
+				formatHistoryUsage(b),
+			)
+		}
+
+		sparkStep := until.Sub(from) / sparklineWidth
+		fmt.Printf("\n%s / %s  %s\n", s.Subscription, s.Metric, sparkline(history.Aggregate(s.Samples, sparkStep)))
+		fmt.Println(t)
+	}
+}
+
+func formatHistoryRange(b history.Bucket) string {
+	if b.Min == b.Max {
+		return format.Number(b.Min)
+	}
+	return format.Number(b.Min) + "-" + format.Number(b.Max)
+}
+
+func formatHistoryUsage(b history.Bucket) string {
+	if b.Limit == nil || *b.Limit <= 0 {
+		return "N/A"
+	}
+	percent := b.Last / *b.Limit * 100
+	return fmt.Sprintf("%s %.0f%% of %s", format.ProgressBar(percent), percent, format.Number(*b.Limit))
+}
+
+// sparkline renders the peak of every bucket. Values are scaled to the
+// metric's limit when it is known so that a full bar means the limit is hit.
+func sparkline(buckets []history.Bucket) string {
+	if len(buckets) == 0 {
+		return ""
+	}
+
+	lo, hi := buckets[0].Max, buckets[0].Max
+	limit := 0.0
+	for _, b := range buckets {
+		lo = min(lo, b.Max)
+		hi = max(hi, b.Max)
+		if b.Limit != nil {
+			limit = max(limit, *b.Limit)
+		}
+	}
+	if limit > 0 {
+		lo, hi = 0, max(limit, hi)
+	}
+
+	var sb strings.Builder
+	for _, b := range buckets {
+		level := 0
+		if hi > lo {
+			level = int((b.Max - lo) / (hi - lo) * float64(len(sparkRunes)-1))
+		}
+		sb.WriteRune(sparkRunes[max(0, min(level, len(sparkRunes)-1))])
+	}
+	return sb.String()
+}
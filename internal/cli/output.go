@@ -3,15 +3,20 @@ package cli
 import (
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
+	"github.com/charmbracelet/x/term"
+	"github.com/user/subscriptions-monitor/internal/format"
 	"github.com/user/subscriptions-monitor/internal/provider"
 )
 
+var staleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
 func PrintJSON(data interface{}) error {
 	b, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
@@ -22,6 +27,58 @@ func PrintJSON(data interface{}) error {
 }
 
 func PrintTable(snapshots []provider.UsageSnapshot) error {
+	fmt.Println(renderTable(snapshots, nil))
+	fmt.Printf("Updated: %s\n", formatRefreshTime(snapshots))
+	return nil
+}
+
+// PrintTableProgressive renders the usage table while results arrive,
+// redrawing it in place as each provider finishes. Rows that are still being
+// fetched are shown as pending. Once the table gets wider than the terminal,
+// whose line wrapping breaks the redraw, only the final table is printed. It
+// returns the snapshots in entry order.
+func PrintTableProgressive(entries []provider.SubscriptionEntry, results <-chan provider.Result) []provider.UsageSnapshot {
+	snapshots := make([]provider.UsageSnapshot, len(entries))
+	pending := make(map[int]bool, len(entries))
+	for i, e := range entries {
+		snapshots[i] = provider.UsageSnapshot{ProviderID: e.Provider, Name: e.Name}
+		pending[i] = true
+	}
+
+	width := terminalWidth(os.Stdout)
+	progressive := true
+	lines := 0
+	draw := func(footer string, final bool) {
+		out := renderTable(snapshots, pending) + "\n" + footer
+		if width > 0 && lipgloss.Width(out) > width {
+			progressive = false
+		}
+		if !progressive && !final {
+			return
+		}
+		if lines > 0 {
+			fmt.Printf("\x1b[%dA\x1b[J", lines)
+		}
+		fmt.Println(out)
+		lines = strings.Count(out, "\n") + 1
+	}
+
+	draw(fmt.Sprintf("Fetching... 0/%d", len(entries)), false)
+	done := 0
+	for res := range results {
+		snapshots[res.Index] = res.Snapshot
+		delete(pending, res.Index)
+		done++
+		if done < len(entries) {
+			draw(fmt.Sprintf("Fetching... %d/%d", done, len(entries)), false)
+		}
+	}
+	draw(fmt.Sprintf("Updated: %s", formatRefreshTime(snapshots)), true)
+
+	return snapshots
+}
+
+func renderTable(snapshots []provider.UsageSnapshot, pending map[int]bool) string {
 	cellStyle := lipgloss.NewStyle().Padding(0, 1)
 
 	t := table.New().
@@ -32,29 +89,139 @@ func PrintTable(snapshots []provider.UsageSnapshot) error {
 		}).
 		Headers("NAME", "PLAN", "USAGE")
 
-	for _, s := range snapshots {
+	for i, s := range snapshots {
+		if pending[i] {
+			t.Row(s.Name, "...", "Fetching...")
+			continue
+		}
+
+		plan := formatPlan(s.Plan)
 		usage := formatUsage(s.Metrics)
-		if s.Status == provider.StatusError {
-			usage = formatErrorUsage(s.Error)
+		if s.Stale {
+			plan = staleStyle.Render(plan)
+			usage = formatStaleUsage(s, usage)
+		} else if s.Status != provider.StatusOK {
+			usage = formatErrorUsage(s.ErrorCode, s.Error)
+		}
+		if s.Status != provider.StatusOK && s.Breaker != nil && s.Breaker.State == provider.BreakerOpen && s.Breaker.RetryAt != nil {
+			usage += fmt.Sprintf("\n  paused, next attempt in %s", format.Duration(time.Until(*s.Breaker.RetryAt)))
 		}
 
 		t.Row(
 			s.Name,
-			formatPlan(s.Plan),
+			plan,
 			usage,
 		)
 	}
 
-	header := "AI Subscriptions Usage"
-	footer := fmt.Sprintf("Updated: %s", formatRefreshTime(snapshots))
+	return "AI Subscriptions Usage\n" + t.String()
+}
+
+func PrintCostTable(results []provider.CostResult, period provider.TimePeriod) error {
+	cellStyle := lipgloss.NewStyle().Padding(0, 1)
+
+	t := table.New().
+		Border(lipgloss.ASCIIBorder()).
+		BorderRow(true).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			return cellStyle
+		}).
+		Headers("NAME", "TOTAL", "BY MODEL")
+
+	for _, r := range results {
+		switch r.Status {
+		case provider.StatusOK:
+			if r.Cost == nil {
+				t.Row(r.Name, "N/A", "")
+				continue
+			}
+			t.Row(
+				r.Name,
+				formatCostTotal(r.Cost),
+				formatCostByModel(r.Cost),
+			)
+		case provider.StatusUnsupported:
+			t.Row(r.Name, "Unsupported", "")
+		default:
+			if r.Cost != nil {
+				t.Row(
+					r.Name,
+					formatCostTotal(r.Cost)+"\n"+formatErrorUsage(r.ErrorCode, r.Error),
+					formatCostByModel(r.Cost),
+				)
+				continue
+			}
+			t.Row(r.Name, formatErrorUsage(r.ErrorCode, r.Error), "")
+		}
+	}
+
+	header := fmt.Sprintf("AI Subscriptions Costs (%s - %s)",
+		period.Start.Format("2006-01-02 15:04"),
+		period.End.Format("2006-01-02 15:04"),
+	)
 
 	fmt.Println(header)
 	fmt.Println(t)
-	fmt.Println(footer)
 
 	return nil
 }
 
+func formatCost(amount float64, currency string) string {
+	if currency == "" {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func formatCostTotal(c *provider.CostBreakdown) string {
+	total := formatCost(c.Total, c.Currency)
+	if c.Input == 0 && c.Output == 0 && c.Other == 0 {
+		return total
+	}
+	return fmt.Sprintf("%s\n  input: %.2f\n  output: %.2f\n  other: %.2f", total, c.Input, c.Output, c.Other)
+}
+
+func formatCostByModel(c *provider.CostBreakdown) string {
+	if len(c.ByModel) == 0 {
+		return "N/A"
+	}
+
+	models := make([]string, 0, len(c.ByModel))
+	for model := range c.ByModel {
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		if c.ByModel[models[i]] != c.ByModel[models[j]] {
+			return c.ByModel[models[i]] > c.ByModel[models[j]]
+		}
+		return models[i] < models[j]
+	})
+
+	var lines []string
+	for _, model := range models {
+		lines = append(lines, fmt.Sprintf("%s: %s", model, formatCost(c.ByModel[model], c.Currency)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidth returns the number of columns of the terminal f, 0 when it
+// cannot be determined.
+func terminalWidth(f *os.File) int {
+	width, _, err := term.GetSize(f.Fd())
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
 func formatRefreshTime(snapshots []provider.UsageSnapshot) string {
 	if len(snapshots) == 0 {
 		return "never"
@@ -62,11 +229,18 @@ func formatRefreshTime(snapshots []provider.UsageSnapshot) string {
 
 	var oldest time.Time
 	for _, s := range snapshots {
+		if s.Timestamp.IsZero() {
+			continue
+		}
 		if oldest.IsZero() || s.Timestamp.Before(oldest) {
 			oldest = s.Timestamp
 		}
 	}
 
+	if oldest.IsZero() {
+		return "never"
+	}
+
 	age := time.Since(oldest)
 	relative := formatAge(age)
 
@@ -108,88 +282,124 @@ func formatUsage(metrics []provider.UsageMetric) string {
 	return strings.Join(parts, "\n")
 }
 
-func formatErrorUsage(errMsg string) string {
+func formatErrorUsage(code provider.ErrorCode, errMsg string) string {
+	if hint := code.Hint(); hint != "" {
+		return fmt.Sprintf("%s: %s", formatErrorTitle(code), hint)
+	}
 	if strings.TrimSpace(errMsg) == "" {
 		return "Fetch failed: unknown error"
 	}
 	return "Fetch failed: " + errMsg
 }
 
+// formatStaleUsage greys out last-known-good values and notes how old they
+// are and why the latest refresh failed.
+func formatStaleUsage(s provider.UsageSnapshot, usage string) string {
+	age := "unknown age"
+	if s.LastSuccessAt != nil {
+		age = format.Duration(time.Since(*s.LastSuccessAt))
+	}
+
+	reason := s.ErrorCode.Hint()
+	if reason == "" {
+		reason = strings.TrimSpace(s.Error)
+	}
+
+	lines := staleStyle.Render(usage) + "\n" + fmt.Sprintf("(stale, %s)", age)
+	if reason != "" {
+		lines += " " + reason
+	}
+	return lines
+}
+
+func formatErrorTitle(code provider.ErrorCode) string {
+	switch code {
+	case provider.ErrorCodeUnauthorized:
+		return "Unauthorized"
+	case provider.ErrorCodeRateLimited:
+		return "Rate limited"
+	case provider.ErrorCodeUnavailable:
+		return "Unavailable"
+	case provider.ErrorCodeTimeout:
+		return "Timeout"
+	default:
+		return "Fetch failed"
+	}
+}
+
 func formatMetric(m provider.UsageMetric) string {
 	if m.Amount.Used == nil && m.Amount.Limit == nil {
 		return fmt.Sprintf("%s: N/A", m.Name)
 	}
 
-	var resetInfo string
-	if m.Window.ResetsAt != nil {
-		remaining := time.Until(*m.Window.ResetsAt)
-		if remaining > 0 {
-			resetInfo = fmt.Sprintf("  resets in %s", formatDuration(remaining))
-		} else {
-			resetInfo = "  resets soon"
-		}
-	}
+	resetInfo := format.Reset(m.Window.ResetsAt, time.Now())
 
 	var usageLine string
 	if m.Amount.Used != nil && m.Amount.Limit != nil {
 		percent := (*m.Amount.Used / *m.Amount.Limit) * 100
-		bar := progressBar(percent)
-		usageLine = fmt.Sprintf("%s %s %.0f%%", m.Name, bar, percent)
+		if m.PaceDelta != nil {
+			elapsed := percent - *m.PaceDelta
+			usageLine = fmt.Sprintf("%s %s %.0f%% used, %.0f%% of window elapsed %s",
+				m.Name, pacedProgressBar(percent, elapsed), percent, elapsed, formatPace(*m.PaceDelta))
+		} else {
+			usageLine = fmt.Sprintf("%s %s %.0f%%", m.Name, format.ProgressBar(percent), percent)
+		}
 	} else if m.Amount.Used != nil {
-		usageLine = fmt.Sprintf("%s: %s %s", m.Name, formatNumber(*m.Amount.Used), m.Amount.Unit)
+		usageLine = fmt.Sprintf("%s: %s %s", m.Name, format.Number(*m.Amount.Used), m.Amount.Unit)
 	} else {
-		usageLine = fmt.Sprintf("%s: -/%s %s", m.Name, formatNumber(*m.Amount.Limit), m.Amount.Unit)
+		usageLine = fmt.Sprintf("%s: -/%s %s", m.Name, format.Number(*m.Amount.Limit), m.Amount.Unit)
 	}
 
 	if resetInfo != "" {
-		return usageLine + "\n" + resetInfo
+		usageLine += "\n  " + resetInfo
+	}
+	if forecast := formatForecast(m); forecast != "" {
+		usageLine += "\n" + forecast
 	}
 	return usageLine
 }
 
-func formatDuration(d time.Duration) string {
-	if d < time.Minute {
-		return fmt.Sprintf("%ds", int(d.Seconds()))
-	}
-	if d < time.Hour {
-		return fmt.Sprintf("%dm", int(d.Minutes()))
-	}
-	if d < 24*time.Hour {
-		return fmt.Sprintf("%dh", int(d.Hours()))
+// formatForecast warns when a metric is on track to run out before its
+// window resets.
+func formatForecast(m provider.UsageMetric) string {
+	f := m.Forecast
+	if f == nil || f.ExhaustsAt == nil || !f.ExhaustsBeforeReset {
+		return ""
 	}
-	days := int(d.Hours()) / 24
-	hours := int(d.Hours()) % 24
-	if hours == 0 {
-		return fmt.Sprintf("%dd", days)
-	}
-	return fmt.Sprintf("%dd%dh", days, hours)
-}
-
-func progressBar(percent float64) string {
-	width := 10
 
-	if percent < 0 || percent != percent {
-		percent = 0
+	remaining := time.Until(*f.ExhaustsAt)
+	if remaining <= 0 {
+		return ""
 	}
-	if percent > 100 {
-		percent = 100
+
+	line := fmt.Sprintf("  at this rate: exhausted in %s", format.ETA(remaining))
+	if m.Window.ResetsAt != nil {
+		line += fmt.Sprintf(" (resets in %s)", format.ETA(time.Until(*m.Window.ResetsAt)))
 	}
+	return line
+}
 
-	filled := int((percent / 100) * float64(width))
-	empty := width - filled
+// pacedProgressBar is format.ProgressBar with a | marking how far an even pace
+// through the window would have got.
+func pacedProgressBar(percent, elapsed float64) string {
+	bar := []rune(format.ProgressBar(percent))
+	width := len(bar) - 2
 
-	return fmt.Sprintf("[%s%s]",
-		strings.Repeat("#", filled),
-		strings.Repeat("-", empty),
-	)
+	pos := int(elapsed / 100 * float64(width))
+	pos = min(max(pos, 0), width-1)
+	bar[pos+1] = '|'
+	return string(bar)
 }
 
-func formatNumber(n float64) string {
-	if n >= 1000000 {
-		return fmt.Sprintf("%.1fM", n/1000000)
-	}
-	if n >= 1000 {
-		return fmt.Sprintf("%.1fK", n/1000)
+// formatPace describes a pace delta, treating less than one percentage point
+// as on pace.
+func formatPace(delta float64) string {
+	switch {
+	case delta >= 1:
+		return fmt.Sprintf("→ +%.0f%% ahead", delta)
+	case delta <= -1:
+		return fmt.Sprintf("→ %.0f%% behind", delta)
+	default:
+		return "→ on pace"
 	}
-	return strconv.FormatFloat(n, 'f', 0, 64)
 }
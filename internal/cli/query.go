@@ -1,10 +1,18 @@
 package cli
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/user/subscriptions-monitor/internal/config"
+	"github.com/user/subscriptions-monitor/internal/history"
 	"github.com/user/subscriptions-monitor/internal/provider"
+	"github.com/user/subscriptions-monitor/internal/store"
 )
 
 var queryCmd = &cobra.Command{
@@ -20,28 +28,134 @@ var queryCmd = &cobra.Command{
 		providerFilter, _ := cmd.Flags().GetString("provider")
 		nameFilter, _ := cmd.Flags().GetString("name")
 
-		var filteredSubs []provider.SubscriptionEntry
-		for _, sub := range cfg.Subscriptions {
-			if providerFilter != "" && sub.Provider != providerFilter {
-				continue
-			}
-			if nameFilter != "" && sub.Name != nameFilter {
-				continue
-			}
-			filteredSubs = append(filteredSubs, sub)
-		}
+		filteredSubs := filterSubscriptions(cfg.Subscriptions, providerFilter, nameFilter)
 
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Settings.Timeout)
 		defer cancel()
 
-		snapshots := registry.FetchAll(ctx, filteredSubs)
+		forecaster := newHistoryForecaster(cfg)
+		entries := loadLastGood(cfg)
 
 		jsonOutput, _ := cmd.Flags().GetBool("json")
 		if jsonOutput {
-			return PrintJSON(snapshots)
+			snapshots := registry.FetchAll(ctx, filteredSubs)
+			forecaster.applyAll(snapshots)
+			recordHistory(cfg, snapshots)
+			return PrintJSON(withLastGood(entries, snapshots))
+		}
+
+		if isTerminal(os.Stdout) {
+			// Warnings would break the in-place redraw, print them afterwards.
+			var warnings bytes.Buffer
+			registry.SetWarningOutput(&warnings)
+			fetched := make([]provider.UsageSnapshot, len(filteredSubs))
+			PrintTableProgressive(filteredSubs, streamLastGood(entries, collectFetched(forecaster.stream(registry.FetchStream(ctx, filteredSubs)), fetched)))
+			registry.SetWarningOutput(nil)
+			os.Stderr.Write(warnings.Bytes())
+			recordHistory(cfg, fetched)
+			return nil
 		}
 
-		PrintTable(snapshots)
+		snapshots := registry.FetchAll(ctx, filteredSubs)
+		forecaster.applyAll(snapshots)
+		recordHistory(cfg, snapshots)
+		PrintTable(withLastGood(entries, snapshots))
 		return nil
 	},
 }
+
+func filterSubscriptions(subs []provider.SubscriptionEntry, providerFilter, nameFilter string) []provider.SubscriptionEntry {
+	var filtered []provider.SubscriptionEntry
+	for _, sub := range subs {
+		if providerFilter != "" && sub.Provider != providerFilter {
+			continue
+		}
+		if nameFilter != "" && sub.Name != nameFilter {
+			continue
+		}
+		filtered = append(filtered, sub)
+	}
+	return filtered
+}
+
+// loadLastGood reads the last-known snapshots kept by serve and collect, so
+// that failed fetches show the last successful values instead. Failures are
+// only warned about.
+func loadLastGood(cfg *config.Config) []store.Entry {
+	entries, err := store.LoadSnapshots(filepath.Join(cfg.Settings.ResolveDataDir(), store.SnapshotsFile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load cached snapshots: %v\n", err)
+	}
+	return entries
+}
+
+func withLastGood(entries []store.Entry, snapshots []provider.UsageSnapshot) []provider.UsageSnapshot {
+	for i := range snapshots {
+		snapshots[i] = store.WithLastGood(entries, snapshots[i])
+	}
+	return snapshots
+}
+
+// streamLastGood applies the last-known-good fallback to results as they
+// arrive.
+func streamLastGood(entries []store.Entry, in <-chan provider.Result) <-chan provider.Result {
+	if len(entries) == 0 {
+		return in
+	}
+	out := make(chan provider.Result, cap(in))
+	go func() {
+		defer close(out)
+		for res := range in {
+			res.Snapshot = store.WithLastGood(entries, res.Snapshot)
+			out <- res
+		}
+	}()
+	return out
+}
+
+// collectFetched stores the results in snapshots as they pass, before the
+// last-known-good fallback replaces what failed, so that the history records
+// what was fetched. snapshots is complete once the returned channel is closed.
+func collectFetched(in <-chan provider.Result, snapshots []provider.UsageSnapshot) <-chan provider.Result {
+	out := make(chan provider.Result, cap(in))
+	go func() {
+		defer close(out)
+		for res := range in {
+			snapshots[res.Index] = res.Snapshot
+			out <- res
+		}
+	}()
+	return out
+}
+
+// recordHistory appends query results to the usage history so that ad-hoc
+// queries fill the same time series as serve. Failures are only warned about.
+func recordHistory(cfg *config.Config, snapshots []provider.UsageSnapshot) {
+	if !cfg.Settings.History.Enabled {
+		return
+	}
+
+	h, err := history.Open(cfg.Settings.ResolveDataDir(), cfg.Settings.History.Options())
+	if err == nil {
+		err = h.Append(snapshots...)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record history: %v\n", err)
+	}
+}
+
+// compactHistory records completed cycles and applies history retention. It
+// is left to serve and collect, plain queries only append.
+func compactHistory(cfg *config.Config) {
+	if !cfg.Settings.History.Enabled {
+		return
+	}
+
+	h, err := history.Open(cfg.Settings.ResolveDataDir(), cfg.Settings.History.Options())
+	if err == nil {
+		err = h.Compact(time.Now())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to compact history: %v\n", err)
+	}
+}
@@ -30,6 +30,10 @@ func init() {
 
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(costsCmd)
+	rootCmd.AddCommand(collectCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(cyclesCmd)
 
 	rootCmd.Flags().BoolP("json", "j", false, "Output as JSON")
 	rootCmd.Flags().StringP("provider", "p", "", "Filter by provider ID")
@@ -43,6 +47,9 @@ func setup(cmd *cobra.Command) (*config.Config, *provider.Registry, error) {
 	}
 
 	registry := provider.NewRegistry()
+	registry.SetRetryPolicy(cfg.Settings.Retry)
+	registry.SetBreakerPolicy(cfg.Settings.CircuitBreaker)
+	registry.SetCallBudget(cfg.Settings.MaxCallsPerHour)
 	adapter.RegisterAll(registry)
 
 	return cfg, registry, nil
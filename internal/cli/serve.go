@@ -41,6 +41,10 @@ var serveCmd = &cobra.Command{
 		fmt.Println("  GET /api/v1/health    - Health check")
 		fmt.Println("  GET /api/v1/usage     - Get usage data (query: provider, name)")
 		fmt.Println("  GET /api/v1/providers - List available providers")
+		fmt.Println("  GET /api/v1/costs     - Get cost breakdown (query: start, end, provider, name)")
+		fmt.Println("  GET /api/v1/history   - Get usage history (query: name, metric, since, until, step)")
+		fmt.Println("  GET /api/v1/cycles    - Get quota cycles and limit hits (query: name, metric, since, until)")
+		fmt.Println("  GET /api/v1/alerts    - Get pending and firing alerts (query: state, name)")
 
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -8,6 +10,9 @@ import (
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/notify"
 	"github.com/user/subscriptions-monitor/internal/provider"
 	"gopkg.in/yaml.v3"
 )
@@ -15,11 +20,56 @@ import (
 type Config struct {
 	Subscriptions []provider.SubscriptionEntry `yaml:"subscriptions" mapstructure:"subscriptions"`
 	Settings      Settings                     `yaml:"settings" mapstructure:"settings"`
+	Alerts        []alert.Rule                 `yaml:"alerts,omitempty" mapstructure:"alerts"`
+	Notifiers     []notify.Config              `yaml:"notifiers,omitempty" mapstructure:"notifiers"`
 }
 
 type Settings struct {
-	Timeout time.Duration `yaml:"timeout" mapstructure:"timeout"`
-	APIPort int           `yaml:"api_port" mapstructure:"api_port"`
+	Timeout         time.Duration          `yaml:"timeout" mapstructure:"timeout"`
+	APIPort         int                    `yaml:"api_port" mapstructure:"api_port"`
+	DataDir         string                 `yaml:"data_dir" mapstructure:"data_dir"`
+	RefreshInterval time.Duration          `yaml:"refresh_interval" mapstructure:"refresh_interval"`
+	RefreshJitter   time.Duration          `yaml:"refresh_jitter" mapstructure:"refresh_jitter"`
+	CacheTTL        time.Duration          `yaml:"cache_ttl" mapstructure:"cache_ttl"`
+	Adaptive        AdaptivePolling        `yaml:"adaptive" mapstructure:"adaptive"`
+	History         HistorySettings        `yaml:"history" mapstructure:"history"`
+	ForecastWindow  time.Duration          `yaml:"forecast_window" mapstructure:"forecast_window"`
+	Retry           provider.RetryPolicy   `yaml:"retry" mapstructure:"retry"`
+	CircuitBreaker  provider.BreakerPolicy `yaml:"circuit_breaker" mapstructure:"circuit_breaker"`
+	// MaxCallsPerHour caps the upstream requests made per subscription and
+	// hour, retries included. 0 means unlimited.
+	MaxCallsPerHour int `yaml:"max_calls_per_hour" mapstructure:"max_calls_per_hour"`
+	// PublicURL is the base URL under which the API server is reachable from
+	// where notifications are read, e.g. https://sub-mon.example.com.
+	// Notifications only link to the server when it is set.
+	PublicURL string `yaml:"public_url,omitempty" mapstructure:"public_url"`
+}
+
+// AdaptivePolling lets serve poll a subscription more often when it is close
+// to a limit or a window reset, and back off while its usage does not change.
+// HighUsage is the used/limit ratio from which MinInterval applies.
+type AdaptivePolling struct {
+	Enabled     bool          `yaml:"enabled" mapstructure:"enabled"`
+	MinInterval time.Duration `yaml:"min_interval" mapstructure:"min_interval"`
+	MaxInterval time.Duration `yaml:"max_interval" mapstructure:"max_interval"`
+	HighUsage   float64       `yaml:"high_usage" mapstructure:"high_usage"`
+}
+
+// HistorySettings controls the usage history recorded by serve and query.
+type HistorySettings struct {
+	Enabled            bool          `yaml:"enabled" mapstructure:"enabled"`
+	Retention          time.Duration `yaml:"retention" mapstructure:"retention"`
+	DownsampleAfter    time.Duration `yaml:"downsample_after" mapstructure:"downsample_after"`
+	DownsampleInterval time.Duration `yaml:"downsample_interval" mapstructure:"downsample_interval"`
+}
+
+// Options converts the settings into history store options.
+func (h HistorySettings) Options() history.Options {
+	return history.Options{
+		Retention:          h.Retention,
+		DownsampleAfter:    h.DownsampleAfter,
+		DownsampleInterval: h.DownsampleInterval,
+	}
 }
 
 func Load(configFile string) (*Config, error) {
@@ -57,8 +107,54 @@ func Load(configFile string) (*Config, error) {
 		return nil, err
 	}
 
+	// Cached snapshots, history and alerts are keyed by subscription name.
+	subs := make(map[string]bool, len(cfg.Subscriptions))
 	for i := range cfg.Subscriptions {
-		cfg.Subscriptions[i].Auth.Key = ExpandEnvVars(cfg.Subscriptions[i].Auth.Key)
+		sub := &cfg.Subscriptions[i]
+		sub.Auth.Key = ExpandEnvVars(sub.Auth.Key)
+		if subs[sub.Name] {
+			return nil, fmt.Errorf("duplicate subscription name %q", sub.Name)
+		}
+		subs[sub.Name] = true
+	}
+
+	if cfg.Settings.CacheTTL < 0 {
+		return nil, fmt.Errorf("cache_ttl must not be negative, got %s", cfg.Settings.CacheTTL)
+	}
+
+	if p := cfg.Settings.PublicURL; p != "" {
+		u, err := url.Parse(p)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("public_url must be an absolute http(s) URL, got %q", p)
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Alerts))
+	for _, r := range cfg.Alerts {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate alert rule %q", r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	for i := range cfg.Notifiers {
+		n := &cfg.Notifiers[i]
+		n.URL = ExpandEnvVars(n.URL)
+		n.Secret = ExpandEnvVars(n.Secret)
+		n.Token = ExpandEnvVars(n.Token)
+		if n.SMTP != nil {
+			n.SMTP.Username = ExpandEnvVars(n.SMTP.Username)
+			n.SMTP.Password = ExpandEnvVars(n.SMTP.Password)
+		}
+		for k, v := range n.Headers {
+			n.Headers[k] = ExpandEnvVars(v)
+		}
+		if err := n.Validate(); err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
@@ -89,12 +185,45 @@ func Save(cfg *Config, configFile string) error {
 func DefaultConfig() *Config {
 	return &Config{
 		Settings: Settings{
-			Timeout: 10 * time.Second,
-			APIPort: 3456,
+			Timeout:         10 * time.Second,
+			APIPort:         3456,
+			RefreshInterval: 60 * time.Second,
+			RefreshJitter:   5 * time.Second,
+			Adaptive: AdaptivePolling{
+				MinInterval: 30 * time.Second,
+				MaxInterval: 15 * time.Minute,
+				HighUsage:   0.8,
+			},
+			History: HistorySettings{
+				Enabled:            true,
+				Retention:          90 * 24 * time.Hour,
+				DownsampleAfter:    7 * 24 * time.Hour,
+				DownsampleInterval: time.Hour,
+			},
+			ForecastWindow: time.Hour,
+			Retry:          provider.DefaultRetryPolicy(),
+			CircuitBreaker: provider.DefaultBreakerPolicy(),
 		},
 	}
 }
 
+// ResolveDataDir returns the directory used for persistent state. It
+// defaults to $XDG_STATE_HOME/sub-mon, or ~/.local/state/sub-mon when
+// XDG_STATE_HOME is not set.
+func (s Settings) ResolveDataDir() string {
+	if s.DataDir != "" {
+		return ExpandEnvVars(s.DataDir)
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "sub-mon")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "sub-mon-data"
+	}
+	return filepath.Join(home, ".local", "state", "sub-mon")
+}
+
 func ExpandEnvVars(s string) string {
 	return os.ExpandEnv(s)
 }
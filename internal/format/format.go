@@ -0,0 +1,74 @@
+package format
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProgressBar draws percent as a bar of ten cells, e.g. "[#####-----]".
+func ProgressBar(percent float64) string {
+	const width = 10
+	if percent < 0 || percent != percent {
+		percent = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	filled := int(percent / 100 * width)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// Duration is d in its largest units, e.g. 45s, 12m, 3h or 2d4h.
+func Duration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	days, hours := int(d.Hours())/24, int(d.Hours())%24
+	if hours == 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dd%dh", days, hours)
+}
+
+// ETA is Duration with minutes kept next to hours, e.g. 1h40m.
+func ETA(d time.Duration) string {
+	if d < time.Hour || d >= 24*time.Hour {
+		return Duration(d)
+	}
+	if m := int(d.Minutes()) % 60; m != 0 {
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), m)
+	}
+	return fmt.Sprintf("%dh", int(d.Hours()))
+}
+
+// Number abbreviates thousands and millions, e.g. 1.5K, and keeps at most two
+// decimals otherwise.
+func Number(n float64) string {
+	if n >= 1000000 {
+		return fmt.Sprintf("%.1fM", n/1000000)
+	}
+	if n >= 1000 {
+		return fmt.Sprintf("%.1fK", n/1000)
+	}
+	return strconv.FormatFloat(math.Round(n*100)/100, 'f', -1, 64)
+}
+
+// Reset describes when a window resets relative to now, e.g. "resets in
+// 2h30m", or "" when resetsAt is unknown.
+func Reset(resetsAt *time.Time, now time.Time) string {
+	if resetsAt == nil {
+		return ""
+	}
+	if d := resetsAt.Sub(now); d > 0 {
+		return "resets in " + ETA(d)
+	}
+	return "resets soon"
+}
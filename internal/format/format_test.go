@@ -0,0 +1,72 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressBar(t *testing.T) {
+	tests := map[float64]string{
+		-5:  "[----------]",
+		0:   "[----------]",
+		55:  "[#####-----]",
+		100: "[##########]",
+		150: "[##########]",
+	}
+	for percent, want := range tests {
+		if got := ProgressBar(percent); got != want {
+			t.Errorf("ProgressBar(%v) = %q, want %q", percent, got, want)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		d         time.Duration
+		duration  string
+		estimated string
+	}{
+		{45 * time.Second, "45s", "45s"},
+		{12 * time.Minute, "12m", "12m"},
+		{time.Hour + 40*time.Minute, "1h", "1h40m"},
+		{3 * time.Hour, "3h", "3h"},
+		{52 * time.Hour, "2d4h", "2d4h"},
+		{48 * time.Hour, "2d", "2d"},
+	}
+	for _, tt := range tests {
+		if got := Duration(tt.d); got != tt.duration {
+			t.Errorf("Duration(%s) = %q, want %q", tt.d, got, tt.duration)
+		}
+		if got := ETA(tt.d); got != tt.estimated {
+			t.Errorf("ETA(%s) = %q, want %q", tt.d, got, tt.estimated)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := map[float64]string{
+		95:      "95",
+		0.355:   "0.36",
+		1500:    "1.5K",
+		2500000: "2.5M",
+	}
+	for n, want := range tests {
+		if got := Number(n); got != want {
+			t.Errorf("Number(%v) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got := Reset(nil, now); got != "" {
+		t.Errorf("expected nothing without a reset time, got %q", got)
+	}
+	upcoming, past := now.Add(2*time.Hour+30*time.Minute), now.Add(-time.Minute)
+	if got := Reset(&upcoming, now); got != "resets in 2h30m" {
+		t.Errorf("unexpected upcoming reset %q", got)
+	}
+	if got := Reset(&past, now); got != "resets soon" {
+		t.Errorf("unexpected past reset %q", got)
+	}
+}
@@ -0,0 +1,517 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CyclesFile is the name of the completed cycle log inside the history directory
+const CyclesFile = "cycles.jsonl"
+
+// Cycle summarizes one quota window of a metric, from one reset to the next.
+// Start is the previous reset, or the first sample when that reset was not
+// observed. For the ongoing cycle Complete is false and End is the time of
+// the latest sample.
+type Cycle struct {
+	Subscription   string     `json:"subscription"`
+	Provider       string     `json:"provider"`
+	Metric         string     `json:"metric"`
+	WindowID       string     `json:"window_id,omitempty"`
+	Start          time.Time  `json:"start"`
+	End            time.Time  `json:"end"`
+	Complete       bool       `json:"complete"`
+	Peak           float64    `json:"peak"`
+	Limit          *float64   `json:"limit,omitempty"`
+	LimitHit       bool       `json:"limit_hit"`
+	HitAt          *time.Time `json:"hit_at,omitempty"`
+	BlockedSeconds int64      `json:"blocked_seconds"`
+	Samples        int        `json:"samples"`
+}
+
+// Blocked returns how long the limit was reached during the cycle.
+func (c Cycle) Blocked() time.Duration {
+	return time.Duration(c.BlockedSeconds) * time.Second
+}
+
+func (c Cycle) seriesKey() string {
+	return c.Subscription + "\x00" + c.Metric
+}
+
+// key identifies a recorded cycle. Processes sharing a data directory may
+// record the same cycle twice.
+func (c Cycle) key() string {
+	return c.seriesKey() + "\x00" + c.End.UTC().Format(time.RFC3339Nano)
+}
+
+func (s Sample) seriesKey() string {
+	return s.Subscription + "\x00" + s.Metric
+}
+
+// cycleBuilder accumulates the samples of one cycle.
+type cycleBuilder struct {
+	cycle       Cycle
+	blocked     time.Duration
+	lastTime    time.Time
+	lastAtLimit bool
+}
+
+func newCycleBuilder(first Sample, start time.Time) *cycleBuilder {
+	return &cycleBuilder{cycle: Cycle{
+		Subscription: first.Subscription,
+		Provider:     first.Provider,
+		Metric:       first.Metric,
+		WindowID:     first.WindowID,
+		Start:        start,
+	}}
+}
+
+func (b *cycleBuilder) add(sample Sample) {
+	c := &b.cycle
+	if b.lastAtLimit {
+		b.blocked += sample.Time.Sub(b.lastTime)
+	}
+	b.lastTime = sample.Time
+	b.lastAtLimit = false
+	c.Samples++
+
+	if sample.Limit != nil {
+		c.Limit = sample.Limit
+	}
+	if sample.Used == nil {
+		return
+	}
+
+	used := *sample.Used
+	c.Peak = max(c.Peak, used)
+	if c.Limit != nil && *c.Limit > 0 && used >= *c.Limit {
+		b.lastAtLimit = true
+		if !c.LimitHit {
+			c.LimitHit = true
+			c.HitAt = &sample.Time
+		}
+	}
+}
+
+// finish closes the cycle at end. A cycle that is still at its limit when it
+// resets counts as blocked until the reset.
+func (b *cycleBuilder) finish(end time.Time, complete bool) Cycle {
+	if complete && b.lastAtLimit {
+		b.blocked += end.Sub(b.lastTime)
+	}
+	b.cycle.End = end
+	b.cycle.Complete = complete
+	b.cycle.BlockedSeconds = int64(b.blocked / time.Second)
+	return b.cycle
+}
+
+// DetectCycles splits the time ordered samples of one series into cycles at
+// the window resets found by Resets. The last cycle is the ongoing one.
+func DetectCycles(samples []Sample) []Cycle {
+	if len(samples) == 0 {
+		return nil
+	}
+	sc := newSeriesCycles(samples[0])
+	for _, sample := range samples[1:] {
+		sc.add(sample)
+	}
+	return append(sc.complete, sc.ongoing())
+}
+
+// seriesCycles detects the cycles of one series sample by sample.
+type seriesCycles struct {
+	complete []Cycle
+	builder  *cycleBuilder
+	last     Sample
+	// after drops cycles that end before the last recorded cycle of the
+	// series, zero when none was recorded.
+	after time.Time
+}
+
+func newSeriesCycles(first Sample) *seriesCycles {
+	b := newCycleBuilder(first, first.Time)
+	b.add(first)
+	return &seriesCycles{builder: b, last: first}
+}
+
+func (sc *seriesCycles) add(sample Sample) {
+	if at, ok := resetBetween(sc.last, sample); ok {
+		sc.complete = append(sc.complete, sc.builder.finish(at, true))
+		sc.builder = newCycleBuilder(sample, at)
+	}
+	sc.builder.add(sample)
+	sc.last = sample
+}
+
+// ongoing returns the cycle in progress without closing it.
+func (sc *seriesCycles) ongoing() Cycle {
+	b := *sc.builder
+	return b.finish(b.lastTime, false)
+}
+
+// cycles returns the completed and the ongoing cycle.
+func (sc *seriesCycles) cycles() []Cycle {
+	var out []Cycle
+	for _, c := range append(sc.complete, sc.ongoing()) {
+		if !sc.after.IsZero() && !c.End.After(sc.after) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// CycleSummary counts how often a metric ran out over a set of cycles.
+type CycleSummary struct {
+	Subscription   string  `json:"subscription"`
+	Metric         string  `json:"metric"`
+	Cycles         int     `json:"cycles"`
+	LimitHits      int     `json:"limit_hits"`
+	HitRate        float64 `json:"hit_rate"`
+	MaxPeak        float64 `json:"max_peak"`
+	BlockedSeconds int64   `json:"blocked_seconds"`
+}
+
+// Summarize aggregates the complete cycles per subscription and metric.
+func Summarize(cycles []Cycle) []CycleSummary {
+	index := make(map[string]int)
+	var out []CycleSummary
+	for _, c := range cycles {
+		if !c.Complete {
+			continue
+		}
+		i, ok := index[c.seriesKey()]
+		if !ok {
+			i = len(out)
+			index[c.seriesKey()] = i
+			out = append(out, CycleSummary{Subscription: c.Subscription, Metric: c.Metric})
+		}
+		sum := &out[i]
+		sum.Cycles++
+		if c.LimitHit {
+			sum.LimitHits++
+		}
+		sum.MaxPeak = max(sum.MaxPeak, c.Peak)
+		sum.BlockedSeconds += c.BlockedSeconds
+	}
+
+	for i := range out {
+		out[i].HitRate = float64(out[i].LimitHits) / float64(out[i].Cycles)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Subscription != out[j].Subscription {
+			return out[i].Subscription < out[j].Subscription
+		}
+		return out[i].Metric < out[j].Metric
+	})
+	return out
+}
+
+// Cycles returns the cycles matching q: the recorded ones, those completed
+// since the last UpdateCycles and the ongoing cycle of every series. A cycle
+// matches when it overlaps the time range of q.
+func (s *Store) Cycles(q Query) ([]Cycle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cache, err := s.cachedCycles()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Cycle
+	for _, c := range append(slices.Clone(cache.recorded), flattenCycles(cache.series)...) {
+		if q.Subscription != "" && c.Subscription != q.Subscription {
+			continue
+		}
+		if q.Metric != "" && c.Metric != q.Metric {
+			continue
+		}
+		if !q.Since.IsZero() && c.End.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && c.Start.After(q.Until) {
+			continue
+		}
+		out = append(out, c)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Subscription != out[j].Subscription {
+			return out[i].Subscription < out[j].Subscription
+		}
+		if out[i].Metric != out[j].Metric {
+			return out[i].Metric < out[j].Metric
+		}
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out, nil
+}
+
+// UpdateCycles records the cycles completed since the last call, so that
+// their statistics survive downsampling and retention of the raw samples.
+func (s *Store) UpdateCycles() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateCycles()
+}
+
+func (s *Store) updateCycles() error {
+	s.cycles = nil
+
+	recorded, _, err := s.readCycles()
+	if err != nil {
+		return err
+	}
+	pending, err := s.pendingCycles(recorded)
+	if err != nil {
+		return err
+	}
+
+	var buf strings.Builder
+	for _, c := range flattenCycles(pending) {
+		if !c.Complete {
+			continue
+		}
+		line, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.cyclesPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(buf.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// cycleCache holds the recorded and pending cycles between compactions.
+// Appended samples are folded into it, so that Cycles does not rescan the
+// history. sizes are the sizes of the history files the cache reflects; when
+// they differ another process wrote to the history and the cache is rebuilt.
+type cycleCache struct {
+	recorded []Cycle
+	series   map[string]*seriesCycles
+	sizes    map[string]int64
+}
+
+func (s *Store) cachedCycles() (*cycleCache, error) {
+	sizes, err := s.fileSizes()
+	if err != nil {
+		return nil, err
+	}
+	if s.cycles != nil && maps.Equal(s.cycles.sizes, sizes) {
+		return s.cycles, nil
+	}
+
+	recorded, _, err := s.readCycles()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.pendingCycles(recorded)
+	if err != nil {
+		return nil, err
+	}
+	s.cycles = &cycleCache{recorded: recorded, series: pending, sizes: sizes}
+	return s.cycles, nil
+}
+
+// fileSizes returns the size of every file in the history directory.
+func (s *Store) fileSizes() (map[string]int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !strings.HasSuffix(e.Name(), segmentExt) {
+			continue
+		}
+		info, err := e.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sizes[e.Name()] = info.Size()
+	}
+	return sizes, nil
+}
+
+// foldCycles adds appended samples to the cached cycles. Samples of unknown
+// series or out of order drop the cache, which is then rebuilt on demand.
+func (s *Store) foldCycles(samples []Sample) {
+	if s.cycles == nil {
+		return
+	}
+	for _, sample := range samples {
+		sc, ok := s.cycles.series[sample.seriesKey()]
+		if !ok || sample.Time.Before(sc.last.Time) {
+			s.cycles = nil
+			return
+		}
+		sc.add(sample)
+	}
+}
+
+// pendingCycles detects the cycles that end after the last recorded cycle of
+// their series, including the ongoing ones, keyed by series.
+func (s *Store) pendingCycles(recorded []Cycle) (map[string]*seriesCycles, error) {
+	lastEnd := make(map[string]time.Time)
+	var since time.Time
+	for _, c := range recorded {
+		if c.End.After(lastEnd[c.seriesKey()]) {
+			lastEnd[c.seriesKey()] = c.End
+		}
+	}
+	for _, end := range lastEnd {
+		if since.IsZero() || end.Before(since) {
+			since = end
+		}
+	}
+
+	samples, err := s.query(Query{Since: since})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make(map[string]*seriesCycles)
+	for _, series := range GroupSeries(samples) {
+		key := series.Subscription + "\x00" + series.Metric
+		end, seen := lastEnd[key]
+		if seen {
+			// Samples from before the last recorded reset belong to cycles
+			// that are already recorded.
+			series.Samples = samplesFrom(series.Samples, end)
+		} else if !since.IsZero() {
+			// A series without recorded cycles may have samples from before
+			// since, look at all of them.
+			all, err := s.query(Query{Subscription: series.Subscription, Metric: series.Metric})
+			if err != nil {
+				return nil, err
+			}
+			series.Samples = all
+		}
+		if len(series.Samples) == 0 {
+			continue
+		}
+
+		sc := newSeriesCycles(series.Samples[0])
+		if seen {
+			sc.builder.cycle.Start = end
+			sc.after = end
+		}
+		for _, sample := range series.Samples[1:] {
+			sc.add(sample)
+		}
+		pending[key] = sc
+	}
+	return pending, nil
+}
+
+// flattenCycles returns the cycles of all series ordered by series.
+func flattenCycles(series map[string]*seriesCycles) []Cycle {
+	var out []Cycle
+	for _, key := range slices.Sorted(maps.Keys(series)) {
+		out = append(out, series[key].cycles()...)
+	}
+	return out
+}
+
+func samplesFrom(samples []Sample, t time.Time) []Sample {
+	i := sort.Search(len(samples), func(i int) bool {
+		return !samples[i].Time.Before(t)
+	})
+	return samples[i:]
+}
+
+func (s *Store) cyclesPath() string {
+	return filepath.Join(s.dir, CyclesFile)
+}
+
+// readCycles returns the recorded cycles without duplicates and whether the
+// file contained any.
+func (s *Store) readCycles() ([]Cycle, bool, error) {
+	f, err := os.Open(s.cyclesPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var cycles []Cycle
+	seen := make(map[string]bool)
+	duplicates := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxSampleLineBytes)
+	for scanner.Scan() {
+		var c Cycle
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			continue
+		}
+		if seen[c.key()] {
+			duplicates = true
+			continue
+		}
+		seen[c.key()] = true
+		cycles = append(cycles, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", s.cyclesPath(), err)
+	}
+	return cycles, duplicates, nil
+}
+
+// pruneCycles drops recorded cycles that ended before cutoff, if not zero,
+// and duplicates recorded by another process.
+func (s *Store) pruneCycles(cutoff time.Time) error {
+	cycles, duplicates, err := s.readCycles()
+	if err != nil {
+		return err
+	}
+
+	var buf strings.Builder
+	pruned := duplicates
+	for _, c := range cycles {
+		if c.End.Before(cutoff) {
+			pruned = true
+			continue
+		}
+		line, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if !pruned {
+		return nil
+	}
+
+	tmp := s.cyclesPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.cyclesPath())
+}
@@ -0,0 +1,212 @@
+package history
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cycleSamples(base time.Time) []Sample {
+	f := func(v float64) *float64 { return &v }
+	limit := f(100)
+	reset1 := base.Add(5 * time.Hour)
+	reset2 := base.Add(10 * time.Hour)
+	sample := func(offset time.Duration, used float64, resetsAt *time.Time) Sample {
+		return Sample{
+			Time:         base.Add(offset),
+			Subscription: "sub-a",
+			Provider:     "zenmux",
+			Metric:       "5h Flows",
+			WindowID:     "5h",
+			Used:         f(used),
+			Limit:        limit,
+			ResetsAt:     resetsAt,
+		}
+	}
+	return []Sample{
+		sample(0, 10, &reset1),
+		sample(2*time.Hour, 60, &reset1),
+		sample(3*time.Hour, 100, &reset1),
+		sample(4*time.Hour, 100, &reset1),
+		sample(6*time.Hour, 5, &reset2),
+		sample(7*time.Hour, 40, &reset2),
+	}
+}
+
+func TestDetectCycles(t *testing.T) {
+	base := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	cycles := DetectCycles(cycleSamples(base))
+	if len(cycles) != 2 {
+		t.Fatalf("expected 2 cycles, got %+v", cycles)
+	}
+
+	c := cycles[0]
+	if !c.Complete || !c.Start.Equal(base) || !c.End.Equal(base.Add(5*time.Hour)) {
+		t.Errorf("unexpected bounds of first cycle: %+v", c)
+	}
+	if c.Peak != 100 || !c.LimitHit || c.HitAt == nil || !c.HitAt.Equal(base.Add(3*time.Hour)) {
+		t.Errorf("expected limit hit at 3h with peak 100, got %+v", c)
+	}
+	if c.Blocked() != 2*time.Hour {
+		t.Errorf("expected blocked 2h until reset, got %s", c.Blocked())
+	}
+
+	ongoing := cycles[1]
+	if ongoing.Complete || !ongoing.Start.Equal(base.Add(5*time.Hour)) || ongoing.Peak != 40 || ongoing.LimitHit {
+		t.Errorf("unexpected ongoing cycle: %+v", ongoing)
+	}
+}
+
+func TestStore_UpdateCycles(t *testing.T) {
+	s, err := Open(t.TempDir(), DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	base := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	samples := cycleSamples(base)
+	if err := s.AppendSamples(samples[:5]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCycles(); err != nil {
+		t.Fatalf("UpdateCycles failed: %v", err)
+	}
+	// A second update must not record the same cycle again.
+	if err := s.UpdateCycles(); err != nil {
+		t.Fatalf("UpdateCycles failed: %v", err)
+	}
+
+	recorded, _, err := s.readCycles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 1 || !recorded[0].LimitHit {
+		t.Fatalf("expected one recorded cycle, got %+v", recorded)
+	}
+
+	if err := s.AppendSamples(samples[5:]); err != nil {
+		t.Fatal(err)
+	}
+	cycles, err := s.Cycles(Query{Subscription: "sub-a"})
+	if err != nil {
+		t.Fatalf("Cycles failed: %v", err)
+	}
+	if len(cycles) != 2 || cycles[1].Complete || !cycles[1].Start.Equal(base.Add(5*time.Hour)) || cycles[1].Samples != 2 {
+		t.Errorf("expected recorded and ongoing cycle, got %+v", cycles)
+	}
+
+	summary := Summarize(cycles)
+	if len(summary) != 1 || summary[0].Cycles != 1 || summary[0].LimitHits != 1 || summary[0].BlockedSeconds != 7200 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestStore_CyclesFoldsAppendedSamples(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	base := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	samples := cycleSamples(base)
+	if err := s.AppendSamples(samples[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Cycles(Query{}); err != nil {
+		t.Fatalf("Cycles failed: %v", err)
+	}
+
+	for _, sample := range samples[2:] {
+		if err := s.AppendSamples([]Sample{sample}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.cycles == nil {
+		t.Fatal("expected appended samples to be folded into the cached cycles")
+	}
+	got, err := s.Cycles(Query{})
+	if err != nil {
+		t.Fatalf("Cycles failed: %v", err)
+	}
+
+	fresh, err := Open(dir, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	want, err := fresh.Cycles(Query{})
+	if err != nil {
+		t.Fatalf("Cycles failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cached cycles differ from a rescan:\n got %+v\nwant %+v", got, want)
+	}
+	if len(got) != 2 || !got[0].Complete {
+		t.Errorf("expected a complete and an ongoing cycle, got %+v", got)
+	}
+}
+
+func TestStore_CyclesSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	b, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	base := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	samples := cycleSamples(base)
+	if err := a.AppendSamples(samples[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Cycles(Query{}); err != nil {
+		t.Fatalf("Cycles failed: %v", err)
+	}
+	cache := a.cycles
+	if _, err := a.Cycles(Query{}); err != nil || a.cycles != cache {
+		t.Fatalf("expected the cache to be reused, got %v", err)
+	}
+
+	// Samples written by another process invalidate the cache.
+	if err := b.AppendSamples(samples[2:5]); err != nil {
+		t.Fatal(err)
+	}
+	cycles, err := a.Cycles(Query{})
+	if err != nil {
+		t.Fatalf("Cycles failed: %v", err)
+	}
+	if len(cycles) != 2 || !cycles[0].Complete {
+		t.Fatalf("expected the cycle completed by the other store, got %+v", cycles)
+	}
+
+	// Both record the completed cycle as if they compacted concurrently.
+	if err := a.UpdateCycles(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(a.cyclesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(a.cyclesPath(), append(data, data...), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if recorded, duplicates, _ := b.readCycles(); len(recorded) != 1 || !duplicates {
+		t.Errorf("expected the duplicate to be skipped, got %+v", recorded)
+	}
+
+	if err := b.Compact(base.Add(8 * time.Hour)); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	data, err = os.ReadFile(a.cyclesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("expected compaction to drop the duplicate, got %d lines", lines)
+	}
+}
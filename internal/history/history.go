@@ -0,0 +1,404 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// DirName is the name of the history directory inside the data directory
+const DirName = "history"
+
+const (
+	segmentExt         = ".jsonl"
+	downsampledExt     = ".ds.jsonl"
+	segmentDateLayout  = "2006-01-02"
+	maxSampleLineBytes = 1 << 20
+)
+
+// Sample is a single observation of a usage metric
+type Sample struct {
+	Time         time.Time  `json:"time"`
+	Subscription string     `json:"subscription"`
+	Provider     string     `json:"provider"`
+	Metric       string     `json:"metric"`
+	WindowID     string     `json:"window_id"`
+	Used         *float64   `json:"used,omitempty"`
+	Limit        *float64   `json:"limit,omitempty"`
+	ResetsAt     *time.Time `json:"resets_at,omitempty"`
+}
+
+// Options controls how long samples are kept. Samples older than
+// DownsampleAfter are thinned out to one per series and DownsampleInterval;
+// samples older than Retention are deleted. Zero values disable the step.
+type Options struct {
+	Retention          time.Duration
+	DownsampleAfter    time.Duration
+	DownsampleInterval time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Retention:          90 * 24 * time.Hour,
+		DownsampleAfter:    7 * 24 * time.Hour,
+		DownsampleInterval: time.Hour,
+	}
+}
+
+// Store is an append-only metric history kept as one JSON-lines segment per
+// UTC day. Segments that have been downsampled are renamed with a .ds suffix
+// so that they are not processed again.
+type Store struct {
+	mu     sync.Mutex
+	dir    string
+	opts   Options
+	cycles *cycleCache
+}
+
+// Open opens (and creates if needed) the history store in dataDir.
+func Open(dataDir string, opts Options) (*Store, error) {
+	dir := filepath.Join(dataDir, DirName)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, opts: opts}, nil
+}
+
+// SamplesFromSnapshot converts the metrics of a snapshot into samples. Stale
+// snapshots and snapshots without metrics yield nothing; failed snapshots
+// that still carry partial metrics are recorded.
+func SamplesFromSnapshot(snap provider.UsageSnapshot) []Sample {
+	if snap.Stale || len(snap.Metrics) == 0 {
+		return nil
+	}
+
+	t := snap.Timestamp
+	if t.IsZero() && snap.FetchedAt != nil {
+		t = *snap.FetchedAt
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	samples := make([]Sample, 0, len(snap.Metrics))
+	for _, m := range snap.Metrics {
+		if m.Amount.Used == nil && m.Amount.Limit == nil {
+			continue
+		}
+		samples = append(samples, Sample{
+			Time:         t.UTC(),
+			Subscription: snap.Name,
+			Provider:     snap.ProviderID,
+			Metric:       m.Name,
+			WindowID:     m.Window.ID,
+			Used:         m.Amount.Used,
+			Limit:        m.Amount.Limit,
+			ResetsAt:     m.Window.ResetsAt,
+		})
+	}
+	return samples
+}
+
+// Append records the metrics of the given snapshots.
+func (s *Store) Append(snaps ...provider.UsageSnapshot) error {
+	var samples []Sample
+	for _, snap := range snaps {
+		samples = append(samples, SamplesFromSnapshot(snap)...)
+	}
+	return s.AppendSamples(samples)
+}
+
+// AppendSamples writes samples to the segment of their day.
+func (s *Store) AppendSamples(samples []Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byDay := make(map[string][]Sample)
+	for _, sample := range samples {
+		day := sample.Time.UTC().Format(segmentDateLayout)
+		byDay[day] = append(byDay[day], sample)
+	}
+
+	for day, daySamples := range byDay {
+		n, err := s.appendSegment(day, daySamples)
+		if err != nil {
+			// Some samples may have been written, rebuild the cycles.
+			s.cycles = nil
+			return err
+		}
+		if s.cycles != nil {
+			s.cycles.sizes[day+segmentExt] += int64(n)
+		}
+	}
+	s.foldCycles(samples)
+	return nil
+}
+
+// appendSegment appends samples to the segment of day and returns the number
+// of bytes written.
+func (s *Store) appendSegment(day string, samples []Sample) (int, error) {
+	var buf strings.Builder
+	for _, sample := range samples {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, day+segmentExt), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.WriteString(buf.String())
+	if err != nil {
+		f.Close()
+		return n, err
+	}
+	return n, f.Close()
+}
+
+// Query selects samples. Empty string fields match everything and zero times
+// leave the range open.
+type Query struct {
+	Subscription string
+	Metric       string
+	Since        time.Time
+	Until        time.Time
+}
+
+func (q Query) matches(sample Sample) bool {
+	if q.Subscription != "" && sample.Subscription != q.Subscription {
+		return false
+	}
+	if q.Metric != "" && sample.Metric != q.Metric {
+		return false
+	}
+	if !q.Since.IsZero() && sample.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && sample.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns the matching samples ordered by time.
+func (s *Store) Query(q Query) ([]Sample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.query(q)
+}
+
+func (s *Store) query(q Query) ([]Sample, error) {
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Sample
+	for _, seg := range segments {
+		if !q.Since.IsZero() && seg.day.Add(24*time.Hour).Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && seg.day.After(q.Until) {
+			continue
+		}
+
+		samples, err := readSegment(seg.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, sample := range samples {
+			if q.matches(sample) {
+				out = append(out, sample)
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Time.Before(out[j].Time)
+	})
+	return out, nil
+}
+
+// Compact records completed cycles, drops duplicates another process
+// recorded and then applies retention and downsampling relative to now.
+func (s *Store) Compact(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.updateCycles(); err != nil {
+		return err
+	}
+	var cutoff time.Time
+	if s.opts.Retention > 0 {
+		cutoff = now.Add(-s.opts.Retention)
+	}
+	if err := s.pruneCycles(cutoff); err != nil {
+		return err
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return err
+	}
+
+	for _, seg := range segments {
+		end := seg.day.Add(24 * time.Hour)
+
+		if s.opts.Retention > 0 && end.Before(now.Add(-s.opts.Retention)) {
+			if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+
+		if seg.downsampled || s.opts.DownsampleAfter <= 0 || s.opts.DownsampleInterval <= 0 {
+			continue
+		}
+		if !end.Before(now.Add(-s.opts.DownsampleAfter)) {
+			continue
+		}
+		if err := s.downsampleSegment(seg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// downsampleSegment keeps the last sample of every series per interval and
+// replaces the segment with its .ds counterpart.
+func (s *Store) downsampleSegment(seg segment) error {
+	samples, err := readSegment(seg.path)
+	if err != nil {
+		return err
+	}
+
+	type bucketKey struct {
+		series string
+		bucket int64
+	}
+	last := make(map[bucketKey]int)
+	var kept []Sample
+	for _, sample := range samples {
+		key := bucketKey{
+			series: sample.Subscription + "\x00" + sample.Metric,
+			bucket: sample.Time.UnixNano() / int64(s.opts.DownsampleInterval),
+		}
+		if i, ok := last[key]; ok {
+			kept[i] = sample
+			continue
+		}
+		last[key] = len(kept)
+		kept = append(kept, sample)
+	}
+
+	var buf strings.Builder
+	for _, sample := range kept {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	target := filepath.Join(s.dir, seg.day.Format(segmentDateLayout)+downsampledExt)
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return err
+	}
+	return os.Remove(seg.path)
+}
+
+type segment struct {
+	path        string
+	day         time.Time
+	downsampled bool
+}
+
+func (s *Store) segments() ([]segment, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		downsampled := strings.HasSuffix(name, downsampledExt)
+		dayStr := strings.TrimSuffix(name, segmentExt)
+		if downsampled {
+			dayStr = strings.TrimSuffix(name, downsampledExt)
+		}
+
+		day, err := time.Parse(segmentDateLayout, dayStr)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{
+			path:        filepath.Join(s.dir, name),
+			day:         day,
+			downsampled: downsampled,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].day.Before(segments[j].day)
+	})
+	return segments, nil
+}
+
+func readSegment(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []Sample
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxSampleLineBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var sample Sample
+		if err := json.Unmarshal(line, &sample); err != nil {
+			// A torn line from an interrupted write should not make the whole
+			// history unreadable.
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return samples, nil
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func testSnapshot(name string, at time.Time, used float64) provider.UsageSnapshot {
+	return provider.UsageSnapshot{
+		ProviderID: "zenmux",
+		Name:       name,
+		Timestamp:  at,
+		Status:     provider.StatusOK,
+		Metrics: []provider.UsageMetric{
+			{
+				Name:   "7d Flows",
+				Window: provider.UsageWindow{ID: "7d"},
+				Amount: provider.UsageAmount{Used: provider.Ptr(used), Limit: provider.Ptr(1200.0)},
+			},
+		},
+	}
+}
+
+func TestStore_AppendAndQuery(t *testing.T) {
+	s, err := Open(t.TempDir(), DefaultOptions())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	base := time.Date(2026, 2, 1, 23, 0, 0, 0, time.UTC)
+	stale := testSnapshot("sub-a", base, 999)
+	stale.Stale = true
+
+	if err := s.Append(
+		testSnapshot("sub-a", base, 10),
+		testSnapshot("sub-b", base, 20),
+		testSnapshot("sub-a", base.Add(2*time.Hour), 30),
+		stale,
+	); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	samples, err := s.Query(Query{Subscription: "sub-a", Metric: "7d Flows"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples across two days, got %d", len(samples))
+	}
+	if *samples[0].Used != 10 || *samples[1].Used != 30 {
+		t.Errorf("expected samples in time order, got %v then %v", *samples[0].Used, *samples[1].Used)
+	}
+	if samples[0].WindowID != "7d" || *samples[0].Limit != 1200 {
+		t.Errorf("unexpected sample fields: %+v", samples[0])
+	}
+
+	samples, _ = s.Query(Query{Since: base.Add(time.Hour)})
+	if len(samples) != 1 {
+		t.Errorf("expected 1 sample after since, got %d", len(samples))
+	}
+}
+
+func TestStore_Compact(t *testing.T) {
+	dataDir := t.TempDir()
+	s, err := Open(dataDir, Options{
+		Retention:          30 * 24 * time.Hour,
+		DownsampleAfter:    7 * 24 * time.Hour,
+		DownsampleInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.AddDate(0, 0, -40)
+	old := time.Date(2026, 2, 10, 10, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+
+	var snaps []provider.UsageSnapshot
+	snaps = append(snaps, testSnapshot("sub", expired, 1))
+	for i := 0; i < 6; i++ {
+		snaps = append(snaps, testSnapshot("sub", old.Add(time.Duration(i)*10*time.Minute), float64(i)))
+	}
+	snaps = append(snaps, testSnapshot("sub", recent, 100), testSnapshot("sub", recent.Add(time.Minute), 101))
+	if err := s.Append(snaps...); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	if err := s.Compact(now); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	samples, err := s.Query(Query{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected expired dropped, old day downsampled to 1, recent kept (3 total), got %d", len(samples))
+	}
+	if *samples[0].Used != 5 {
+		t.Errorf("expected downsampling to keep the last sample of the hour, got %v", *samples[0].Used)
+	}
+
+	if _, err := os.Stat(filepath.Join(dataDir, DirName, "2026-02-10"+downsampledExt)); err != nil {
+		t.Errorf("expected downsampled segment to be marked: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Series is the samples of one metric of one subscription, ordered by time.
+type Series struct {
+	Subscription string   `json:"subscription"`
+	Provider     string   `json:"provider"`
+	Metric       string   `json:"metric"`
+	Samples      []Sample `json:"samples"`
+}
+
+// GroupSeries splits time ordered samples into one series per subscription
+// and metric, sorted by subscription and metric name.
+func GroupSeries(samples []Sample) []Series {
+	index := make(map[string]int)
+	var series []Series
+	for _, sample := range samples {
+		key := sample.Subscription + "\x00" + sample.Metric
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, Series{
+				Subscription: sample.Subscription,
+				Provider:     sample.Provider,
+				Metric:       sample.Metric,
+			})
+		}
+		series[i].Samples = append(series[i].Samples, sample)
+	}
+
+	sort.SliceStable(series, func(i, j int) bool {
+		if series[i].Subscription != series[j].Subscription {
+			return series[i].Subscription < series[j].Subscription
+		}
+		return series[i].Metric < series[j].Metric
+	})
+	return series
+}
+
+// Bucket summarizes the used values of the samples within one step.
+type Bucket struct {
+	Time    time.Time `json:"time"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	Last    float64   `json:"last"`
+	Limit   *float64  `json:"limit,omitempty"`
+	Samples int       `json:"samples"`
+}
+
+// Aggregate groups time ordered samples into buckets of step, aligned to
+// multiples of step. Samples without a used value are ignored and empty
+// buckets are omitted. A step <= 0 yields one bucket per sample.
+func Aggregate(samples []Sample, step time.Duration) []Bucket {
+	var buckets []Bucket
+	for _, sample := range samples {
+		if sample.Used == nil {
+			continue
+		}
+		used := *sample.Used
+
+		start := sample.Time
+		if step > 0 {
+			start = sample.Time.Truncate(step)
+		}
+
+		if n := len(buckets); n > 0 && step > 0 && buckets[n-1].Time.Equal(start) {
+			b := &buckets[n-1]
+			b.Min = min(b.Min, used)
+			b.Max = max(b.Max, used)
+			b.Last = used
+			if sample.Limit != nil {
+				b.Limit = sample.Limit
+			}
+			b.Samples++
+			continue
+		}
+
+		buckets = append(buckets, Bucket{
+			Time:    start,
+			Min:     used,
+			Max:     used,
+			Last:    used,
+			Limit:   sample.Limit,
+			Samples: 1,
+		})
+	}
+	return buckets
+}
+
+// Reset marks the point at which a quota window started over.
+type Reset struct {
+	Time     time.Time `json:"time"`
+	WindowID string    `json:"window_id,omitempty"`
+}
+
+// resetTolerance absorbs reset times that providers derive from "now plus
+// remaining time" and that therefore drift by a few seconds between fetches.
+const resetTolerance = time.Minute
+
+// Resets detects window resets in the time ordered samples of one series. A
+// reset happened when the reported reset time moved forward, at the previously
+// reported reset time; for metrics without reset times a drop of the used
+// value is taken as a reset instead.
+func Resets(samples []Sample) []Reset {
+	var resets []Reset
+	for i := 1; i < len(samples); i++ {
+		if at, ok := resetBetween(samples[i-1], samples[i]); ok {
+			resets = append(resets, Reset{Time: at, WindowID: samples[i].WindowID})
+		}
+	}
+	return resets
+}
+
+func resetBetween(prev, cur Sample) (time.Time, bool) {
+	if prev.ResetsAt != nil && cur.ResetsAt != nil {
+		if cur.ResetsAt.Sub(*prev.ResetsAt) <= resetTolerance {
+			return time.Time{}, false
+		}
+		at := *prev.ResetsAt
+		if at.Before(prev.Time) || at.After(cur.Time) {
+			at = cur.Time
+		}
+		return at, true
+	}
+
+	if prev.Used != nil && cur.Used != nil && *cur.Used < *prev.Used {
+		return cur.Time, true
+	}
+	return time.Time{}, false
+}
+
+// steps are the candidate aggregation intervals picked by StepFor.
+var steps = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// StepFor returns the smallest round interval that splits span into at most
+// maxPoints buckets.
+func StepFor(span time.Duration, maxPoints int) time.Duration {
+	if maxPoints <= 0 {
+		maxPoints = 1
+	}
+	for _, step := range steps {
+		if span/step <= time.Duration(maxPoints) {
+			return step
+		}
+	}
+	day := 24 * time.Hour
+	days := (span/time.Duration(maxPoints) + day - 1) / day
+	return days * day
+}
+
+// ParseTime parses an absolute time (RFC3339 or YYYY-MM-DD) or a duration
+// before now such as 90m, 12h or 7d. An empty string yields the zero time.
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if s == "now" {
+		return now, nil
+	}
+
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339, YYYY-MM-DD or a duration like 7d", s)
+}
+
+// ParseDuration is time.ParseDuration with an additional d (day) unit.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
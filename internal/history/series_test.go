@@ -0,0 +1,137 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupSeries(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: base, Subscription: "b", Metric: "m"},
+		{Time: base, Subscription: "a", Metric: "y"},
+		{Time: base.Add(time.Minute), Subscription: "a", Metric: "x"},
+		{Time: base.Add(2 * time.Minute), Subscription: "b", Metric: "m"},
+	}
+
+	series := GroupSeries(samples)
+	if len(series) != 3 {
+		t.Fatalf("expected 3 series, got %d", len(series))
+	}
+	if series[0].Subscription != "a" || series[0].Metric != "x" || series[2].Subscription != "b" {
+		t.Errorf("unexpected order: %+v", series)
+	}
+	if len(series[2].Samples) != 2 {
+		t.Errorf("expected 2 samples for b/m, got %d", len(series[2].Samples))
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := 100.0
+	sample := func(offset time.Duration, used float64) Sample {
+		return Sample{Time: base.Add(offset), Used: &used, Limit: &limit}
+	}
+	samples := []Sample{
+		sample(0, 10),
+		sample(20*time.Minute, 30),
+		sample(40*time.Minute, 20),
+		{Time: base.Add(50 * time.Minute)},
+		sample(70*time.Minute, 50),
+	}
+
+	buckets := Aggregate(samples, time.Hour)
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(buckets))
+	}
+	b := buckets[0]
+	if !b.Time.Equal(base) || b.Min != 10 || b.Max != 30 || b.Last != 20 || b.Samples != 3 {
+		t.Errorf("unexpected first bucket: %+v", b)
+	}
+	if !buckets[1].Time.Equal(base.Add(time.Hour)) || buckets[1].Last != 50 {
+		t.Errorf("unexpected second bucket: %+v", buckets[1])
+	}
+
+	if raw := Aggregate(samples, 0); len(raw) != 4 {
+		t.Errorf("expected one bucket per sample without step, got %d", len(raw))
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"now", now},
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"2026-01-05", time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"2026-01-05T08:00:00Z", time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in, now)
+		if err != nil {
+			t.Errorf("ParseTime(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"yesterday", "-3d", "3x"} {
+		if _, err := ParseTime(in, now); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
+
+func TestResets(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := func(v float64) *float64 { return &v }
+	at := func(d time.Duration) *time.Time { r := base.Add(d); return &r }
+
+	samples := []Sample{
+		{Time: base, Used: f(10), ResetsAt: at(time.Hour)},
+		{Time: base.Add(30 * time.Minute), Used: f(40), ResetsAt: at(time.Hour + 10*time.Second)},
+		{Time: base.Add(70 * time.Minute), Used: f(5), ResetsAt: at(6 * time.Hour), WindowID: "5h"},
+	}
+	resets := Resets(samples)
+	if len(resets) != 1 {
+		t.Fatalf("expected 1 reset, got %+v", resets)
+	}
+	if !resets[0].Time.Equal(base.Add(time.Hour+10*time.Second)) || resets[0].WindowID != "5h" {
+		t.Errorf("unexpected reset: %+v", resets[0])
+	}
+
+	noResetTime := []Sample{
+		{Time: base, Used: f(10)},
+		{Time: base.Add(time.Minute), Used: f(20)},
+		{Time: base.Add(2 * time.Minute), Used: f(0)},
+	}
+	resets = Resets(noResetTime)
+	if len(resets) != 1 || !resets[0].Time.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("expected reset on drop of used, got %+v", resets)
+	}
+}
+
+func TestStepFor(t *testing.T) {
+	tests := []struct {
+		span time.Duration
+		max  int
+		want time.Duration
+	}{
+		{time.Hour, 200, time.Minute},
+		{24 * time.Hour, 24, time.Hour},
+		{7 * 24 * time.Hour, 24, 12 * time.Hour},
+		{90 * 24 * time.Hour, 24, 4 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := StepFor(tt.span, tt.max); got != tt.want {
+			t.Errorf("StepFor(%s, %d) = %s, want %s", tt.span, tt.max, got, tt.want)
+		}
+	}
+}
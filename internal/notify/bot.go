@@ -0,0 +1,259 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newBot builds the Feishu (Lark), DingTalk or WeCom group robot notifier.
+func newBot(c Config) (Notifier, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	client := &http.Client{Timeout: c.Timeout}
+
+	switch c.Type {
+	case "dingtalk":
+		return &dingtalk{config: c, client: client, now: time.Now}, nil
+	case "wecom":
+		if c.Secret != "" {
+			return nil, fmt.Errorf("wecom robots do not support a secret")
+		}
+		return &wecom{config: c, client: client}, nil
+	default:
+		return &feishu{config: c, client: client, now: time.Now}, nil
+	}
+}
+
+// postJSON POSTs payload as JSON to rawURL and returns the response body.
+func postJSON(ctx context.Context, client *http.Client, c Config, rawURL string, payload any) ([]byte, error) {
+	return sendJSON(ctx, client, c, http.MethodPost, rawURL, nil, payload)
+}
+
+// sendJSON sends payload as JSON with the given method and extra headers and
+// returns the response body.
+func sendJSON(ctx context.Context, client *http.Client, c Config, method, rawURL string, headers map[string]string, payload any) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return send(ctx, client, c.Retry, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "sub-mon")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
+	})
+}
+
+// botResult is the status part of Feishu, DingTalk and WeCom responses,
+// which answer 200 and report failures in the body. Older Feishu bots use
+// StatusCode instead of code.
+type botResult struct {
+	Code          *int   `json:"code"`
+	Msg           string `json:"msg"`
+	ErrCode       *int   `json:"errcode"`
+	ErrMsg        string `json:"errmsg"`
+	StatusCode    *int   `json:"StatusCode"`
+	StatusMessage string `json:"StatusMessage"`
+}
+
+func checkBotResult(body []byte) error {
+	var r botResult
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("unexpected response: %s", body)
+	}
+	for _, c := range []struct {
+		code *int
+		msg  string
+	}{{r.Code, r.Msg}, {r.ErrCode, r.ErrMsg}, {r.StatusCode, r.StatusMessage}} {
+		if c.code != nil && *c.code != 0 {
+			return fmt.Errorf("error %d: %s", *c.code, c.msg)
+		}
+	}
+	return nil
+}
+
+func hmacSHA256Base64(key, msg string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// feishu sends interactive cards to a Feishu or Lark custom bot.
+type feishu struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+}
+
+var feishuTemplates = map[string]string{
+	toneOK:       "green",
+	toneInfo:     "blue",
+	toneWarning:  "orange",
+	toneCritical: "red",
+}
+
+// feishuSign is the signature of a Feishu bot with signing enabled: the
+// HMAC-SHA256 keyed with "timestamp\nsecret" of an empty message.
+func feishuSign(secret string, ts int64) string {
+	return hmacSHA256Base64(fmt.Sprintf("%d\n%s", ts, secret), "")
+}
+
+func (f *feishu) payload(ev Event) map[string]any {
+	c := newCard(ev)
+
+	var fields []map[string]any
+	for _, fl := range c.Fields {
+		fields = append(fields, map[string]any{
+			"is_short": false,
+			"text":     map[string]string{"tag": "lark_md", "content": fmt.Sprintf("**%s**\n%s", fl.Name, fl.Value)},
+		})
+	}
+
+	payload := map[string]any{
+		"msg_type": "interactive",
+		"card": map[string]any{
+			"config": map[string]any{"wide_screen_mode": true},
+			"header": map[string]any{
+				"title":    map[string]string{"tag": "plain_text", "content": c.Title},
+				"template": feishuTemplates[c.Tone],
+			},
+			"elements": []any{
+				map[string]any{"tag": "div", "fields": fields},
+				map[string]any{"tag": "note", "elements": []any{
+					map[string]string{"tag": "plain_text", "content": "sub-mon · " + ev.Time.UTC().Format(time.RFC3339)},
+				}},
+			},
+		},
+	}
+
+	if f.config.Secret != "" {
+		ts := f.now().Unix()
+		payload["timestamp"] = strconv.FormatInt(ts, 10)
+		payload["sign"] = feishuSign(f.config.Secret, ts)
+	}
+	return payload
+}
+
+func (f *feishu) Notify(ctx context.Context, ev Event) error {
+	body, err := postJSON(ctx, f.client, f.config, f.config.URL, f.payload(ev))
+	if err != nil {
+		return err
+	}
+	return checkBotResult(body)
+}
+
+// dingtalk sends markdown messages to a DingTalk group robot.
+type dingtalk struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+}
+
+var dingtalkColors = map[string]string{
+	toneOK:       "#2E7D32",
+	toneInfo:     "#1565C0",
+	toneWarning:  "#EF6C00",
+	toneCritical: "#C62828",
+}
+
+// signedURL appends the timestamp in milliseconds and the HMAC-SHA256 of
+// "timestamp\nsecret" keyed with the secret, as required by robots with
+// signing enabled.
+func (d *dingtalk) signedURL() (string, error) {
+	if d.config.Secret == "" {
+		return d.config.URL, nil
+	}
+	u, err := url.Parse(d.config.URL)
+	if err != nil {
+		return "", err
+	}
+	ts := strconv.FormatInt(d.now().UnixMilli(), 10)
+	q := u.Query()
+	q.Set("timestamp", ts)
+	q.Set("sign", hmacSHA256Base64(d.config.Secret, ts+"\n"+d.config.Secret))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (d *dingtalk) payload(ev Event) map[string]any {
+	c := newCard(ev)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### <font color=\"%s\">%s</font>\n\n", dingtalkColors[c.Tone], c.Title)
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "- **%s**: %s\n", f.Name, f.Value)
+	}
+	fmt.Fprintf(&b, "\n###### sub-mon · %s", ev.Time.UTC().Format(time.RFC3339))
+
+	return map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": c.Title, "text": b.String()},
+	}
+}
+
+func (d *dingtalk) Notify(ctx context.Context, ev Event) error {
+	u, err := d.signedURL()
+	if err != nil {
+		return err
+	}
+	body, err := postJSON(ctx, d.client, d.config, u, d.payload(ev))
+	if err != nil {
+		return err
+	}
+	return checkBotResult(body)
+}
+
+// wecom sends markdown messages to a WeCom group robot. Its webhook key is
+// part of the URL, there is no signing.
+type wecom struct {
+	config Config
+	client *http.Client
+}
+
+// WeCom markdown only knows three font colours.
+var wecomColors = map[string]string{
+	toneOK:       "info",
+	toneInfo:     "comment",
+	toneWarning:  "warning",
+	toneCritical: "warning",
+}
+
+func (w *wecom) payload(ev Event) map[string]any {
+	c := newCard(ev)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**<font color=\"%s\">%s</font>**\n", wecomColors[c.Tone], c.Title)
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "> %s: <font color=\"comment\">%s</font>\n", f.Name, f.Value)
+	}
+
+	return map[string]any{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": b.String()},
+	}
+}
+
+func (w *wecom) Notify(ctx context.Context, ev Event) error {
+	body, err := postJSON(ctx, w.client, w.config, w.config.URL, w.payload(ev))
+	if err != nil {
+		return err
+	}
+	return checkBotResult(body)
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var fixedNow = func() time.Time { return time.Unix(1700000000, 0) }
+
+func TestFeishu(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return `{"code":0,"msg":"success","data":{}}` })
+
+	n := &feishu{config: Config{Name: "lark", URL: srv.URL, Secret: "s3cret"}.withDefaults(), client: http.DefaultClient, now: fixedNow}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	p := requests()[0].Body
+	if p["msg_type"] != "interactive" || p["timestamp"] != "1700000000" {
+		t.Errorf("unexpected payload: %v", p)
+	}
+	mac := hmac.New(sha256.New, []byte("1700000000\ns3cret"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); p["sign"] != want {
+		t.Errorf("expected sign %q, got %v", want, p["sign"])
+	}
+
+	header := p["card"].(map[string]any)["header"].(map[string]any)
+	if header["template"] != "orange" {
+		t.Errorf("expected an orange header for a warning, got %v", header["template"])
+	}
+	if title := header["title"].(map[string]any)["content"]; title != "high firing: sub-a 5h" {
+		t.Errorf("unexpected title %v", title)
+	}
+}
+
+func TestFeishu_ErrorInBody(t *testing.T) {
+	srv, _ := recordingServer(t, func(recordedRequest) string { return `{"code":19021,"msg":"sign match fail"}` })
+
+	n, err := New(Config{Name: "lark", Type: "feishu", URL: srv.URL, Retry: fastRetry(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err == nil || !strings.Contains(err.Error(), "sign match fail") {
+		t.Errorf("expected the bot error, got %v", err)
+	}
+}
+
+func TestDingTalk(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return `{"errcode":0,"errmsg":"ok"}` })
+
+	n := &dingtalk{config: Config{Name: "ding", URL: srv.URL + "/robot/send?access_token=abc", Secret: "SECxyz"}.withDefaults(), client: http.DefaultClient, now: fixedNow}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	r := requests()[0]
+	q, _ := url.ParseQuery(r.Query)
+	if q.Get("access_token") != "abc" || q.Get("timestamp") != "1700000000000" {
+		t.Errorf("unexpected query %v", q)
+	}
+	mac := hmac.New(sha256.New, []byte("SECxyz"))
+	mac.Write([]byte("1700000000000\nSECxyz"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); q.Get("sign") != want {
+		t.Errorf("expected sign %q, got %q", want, q.Get("sign"))
+	}
+
+	md := r.Body["markdown"].(map[string]any)
+	text := md["text"].(string)
+	if r.Body["msgtype"] != "markdown" || !strings.Contains(text, "[#########-] 95% (95 / 100)") {
+		t.Errorf("unexpected payload: %v", r.Body)
+	}
+}
+
+func TestWeCom(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return `{"errcode":0,"errmsg":"ok"}` })
+
+	n, err := New(Config{Name: "wecom", Type: "wecom", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	content := requests()[0].Body["markdown"].(map[string]any)["content"].(string)
+	if !strings.HasPrefix(content, `**<font color="warning">high firing: sub-a 5h</font>**`) || !strings.Contains(content, "> Subscription:") {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	if _, err := New(Config{Name: "wecom", Type: "wecom", URL: srv.URL, Secret: "x"}); err == nil {
+		t.Error("expected an error for a wecom secret")
+	}
+}
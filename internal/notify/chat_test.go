@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+)
+
+func resolvedEvent() Event {
+	ev := testEvent()
+	ev.Kind = KindResolved
+	ev.Alert.State = alert.StateResolved
+	return ev
+}
+
+func attachmentColor(body map[string]any) any {
+	return body["attachments"].([]any)[0].(map[string]any)["color"]
+}
+
+func TestSlack_IncomingWebhook(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return "ok" })
+
+	n, err := New(Config{Name: "slack", Type: "slack", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 || attachmentColor(reqs[0].Body) != slackColors[toneWarning] || reqs[0].Body["text"] == "" {
+		t.Errorf("unexpected requests: %+v", reqs)
+	}
+}
+
+func TestSlack_ThreadsResolve(t *testing.T) {
+	srv, requests := recordingServer(t, func(r recordedRequest) string {
+		return `{"ok":true,"channel":"C123","ts":"1700000000.000100"}`
+	})
+
+	n, err := New(Config{Name: "slack", Type: "slack", URL: srv.URL, Token: "xoxb-1", Channel: "#alerts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify firing: %v", err)
+	}
+	if err := n.Notify(context.Background(), resolvedEvent()); err != nil {
+		t.Fatalf("Notify resolved: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 3 {
+		t.Fatalf("expected post, update and reply, got %+v", reqs)
+	}
+	if reqs[0].Path != "/chat.postMessage" || reqs[0].Body["channel"] != "#alerts" || reqs[0].Auth != "Bearer xoxb-1" {
+		t.Errorf("unexpected first request: %+v", reqs[0])
+	}
+	if r := reqs[1]; r.Path != "/chat.update" || r.Body["channel"] != "C123" || r.Body["ts"] != "1700000000.000100" || attachmentColor(r.Body) != slackColors[toneOK] {
+		t.Errorf("expected the original message to be updated, got %+v", r)
+	}
+	if r := reqs[2]; r.Path != "/chat.postMessage" || r.Body["thread_ts"] != "1700000000.000100" {
+		t.Errorf("expected a thread reply, got %+v", r)
+	}
+}
+
+func TestSlack_ThreadsSurviveRestart(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string {
+		return `{"ok":true,"channel":"C123","ts":"1700000000.000100"}`
+	})
+	path := filepath.Join(t.TempDir(), ThreadsFile)
+
+	notifier := func() Notifier {
+		threads, err := LoadThreads(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := New(Config{Name: "slack", Type: "slack", URL: srv.URL, Token: "xoxb-1", Channel: "#alerts"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.(threaded).useThreads(threads, "slack")
+		return n
+	}
+
+	if err := notifier().Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify firing: %v", err)
+	}
+	if err := notifier().Notify(context.Background(), resolvedEvent()); err != nil {
+		t.Fatalf("Notify resolved: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 3 || reqs[1].Path != "/chat.update" || reqs[1].Body["ts"] != "1700000000.000100" {
+		t.Fatalf("expected the message posted before the restart to be updated, got %+v", reqs)
+	}
+	if threads, _ := LoadThreads(path); len(threads.refs) != 0 {
+		t.Errorf("expected the closed thread to be forgotten, got %v", threads.refs)
+	}
+}
+
+func TestSlack_KeepsThreadWhenUpdateFails(t *testing.T) {
+	updates := 0
+	srv, requests := recordingServer(t, func(r recordedRequest) string {
+		if r.Path == "/chat.update" {
+			if updates++; updates == 1 {
+				return `{"ok":false,"error":"ratelimited"}`
+			}
+		}
+		return `{"ok":true,"channel":"C123","ts":"1700000000.000100"}`
+	})
+
+	n, err := New(Config{Name: "slack", Type: "slack", URL: srv.URL, Token: "xoxb-1", Channel: "#alerts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify firing: %v", err)
+	}
+	if err := n.Notify(context.Background(), resolvedEvent()); err == nil {
+		t.Fatal("expected the failed update to be reported")
+	}
+	if err := n.Notify(context.Background(), resolvedEvent()); err != nil {
+		t.Fatalf("Notify resolved: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 4 || reqs[2].Path != "/chat.update" || reqs[2].Body["ts"] != "1700000000.000100" {
+		t.Errorf("expected the update to be retried on the original message, got %+v", reqs)
+	}
+}
+
+func TestSlack_APIError(t *testing.T) {
+	srv, _ := recordingServer(t, func(recordedRequest) string { return `{"ok":false,"error":"channel_not_found"}` })
+
+	n, err := New(Config{Name: "slack", Type: "slack", URL: srv.URL, Token: "xoxb-1", Channel: "#nope"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := New(Config{Name: "slack", Type: "slack", Token: "xoxb-1"}); err == nil {
+		t.Error("expected an error for a token without channel")
+	}
+}
+
+func TestDiscord_EditsOnResolve(t *testing.T) {
+	srv, requests := recordingServer(t, func(r recordedRequest) string { return `{"id":"42"}` })
+
+	n, err := New(Config{Name: "discord", Type: "discord", URL: srv.URL + "/api/webhooks/1/abc?thread_id=7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify firing: %v", err)
+	}
+	if err := n.Notify(context.Background(), resolvedEvent()); err != nil {
+		t.Fatalf("Notify resolved: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 2 {
+		t.Fatalf("expected post and edit, got %+v", reqs)
+	}
+	if r := reqs[0]; r.Method != http.MethodPost || r.Path != "/api/webhooks/1/abc" || r.Query != "thread_id=7&wait=true" {
+		t.Errorf("unexpected post: %+v", r)
+	}
+	embed := reqs[0].Body["embeds"].([]any)[0].(map[string]any)
+	if embed["color"] != float64(discordColors[toneWarning]) || embed["title"] != "high firing: sub-a 5h" {
+		t.Errorf("unexpected embed: %v", embed)
+	}
+	if r := reqs[1]; r.Method != http.MethodPatch || r.Path != "/api/webhooks/1/abc/messages/42" {
+		t.Errorf("expected the message to be edited, got %+v", r)
+	}
+	embed = reqs[1].Body["embeds"].([]any)[0].(map[string]any)
+	if embed["color"] != float64(discordColors[toneOK]) {
+		t.Errorf("expected a green embed on resolve, got %v", embed["color"])
+	}
+}
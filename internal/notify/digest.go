@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestConfig schedules a usage digest. It is sent every day, or every
+// Weekday for weekly digests, at At local time and covers the day or week
+// before.
+type DigestConfig struct {
+	Schedule string `yaml:"schedule" mapstructure:"schedule"`
+	At       string `yaml:"at,omitempty" mapstructure:"at"`
+	Weekday  string `yaml:"weekday,omitempty" mapstructure:"weekday"`
+}
+
+const defaultDigestAt = "08:00"
+
+func (d DigestConfig) Validate() error {
+	if d.Schedule != DigestDaily && d.Schedule != DigestWeekly {
+		return fmt.Errorf("digest schedule must be daily or weekly, got %q", d.Schedule)
+	}
+	if _, _, err := d.clock(); err != nil {
+		return err
+	}
+	if _, err := d.weekday(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d DigestConfig) clock() (hour, minute int, err error) {
+	at := d.At
+	if at == "" {
+		at = defaultDigestAt
+	}
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return 0, 0, fmt.Errorf("digest time must be HH:MM, got %q", d.At)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+func (d DigestConfig) weekday() (time.Weekday, error) {
+	if d.Weekday == "" {
+		return time.Monday, nil
+	}
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if strings.EqualFold(d.Weekday, wd.String()) || strings.EqualFold(d.Weekday, wd.String()[:3]) {
+			return wd, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown digest weekday %q", d.Weekday)
+}
+
+// Next returns the first scheduled time after t, in t's location.
+func (d DigestConfig) Next(t time.Time) time.Time {
+	hour, minute, _ := d.clock()
+	wd, _ := d.weekday()
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location())
+	for !next.After(t) || (d.Schedule == DigestWeekly && next.Weekday() != wd) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Period returns the range covered by a digest sent at t.
+func (d DigestConfig) Period(t time.Time) provider.TimePeriod {
+	days := 1
+	if d.Schedule == DigestWeekly {
+		days = 7
+	}
+	return provider.TimePeriod{Start: t.AddDate(0, 0, -days), End: t}
+}
+
+// DigestNotifier is implemented by notifiers that can send digests.
+type DigestNotifier interface {
+	SendDigest(ctx context.Context, d Digest) error
+}
+
+// Digest summarises every subscription over a period.
+type Digest struct {
+	Schedule      string
+	Period        provider.TimePeriod
+	Subscriptions []DigestEntry
+}
+
+// DigestEntry is one subscription of a digest: its latest snapshot, the
+// peak usage of every window that overlapped the period and its cost over
+// the period when the provider reports one.
+type DigestEntry struct {
+	Snapshot provider.UsageSnapshot
+	Windows  []WindowPeak
+	Cost     *provider.CostBreakdown
+}
+
+// WindowPeak is the highest usage a metric reached in the windows that
+// overlapped the period.
+type WindowPeak struct {
+	Metric    string
+	Windows   int
+	Peak      float64
+	Limit     *float64
+	LimitHits int
+	Blocked   time.Duration
+}
+
+// NewDigest assembles a digest from the subscriptions' snapshots, their
+// quota cycles overlapping the period and their cost results.
+func NewDigest(schedule string, period provider.TimePeriod, snaps []provider.UsageSnapshot, cycles []history.Cycle, costs []provider.CostResult) Digest {
+	d := Digest{Schedule: schedule, Period: period}
+
+	peaks := make(map[string][]WindowPeak)
+	index := make(map[string]int)
+	for _, c := range cycles {
+		key := c.Subscription + "/" + c.Metric
+		i, ok := index[key]
+		if !ok {
+			i = len(peaks[c.Subscription])
+			index[key] = i
+			peaks[c.Subscription] = append(peaks[c.Subscription], WindowPeak{Metric: c.Metric})
+		}
+		p := &peaks[c.Subscription][i]
+		p.Windows++
+		if c.Peak >= p.Peak {
+			p.Peak = c.Peak
+			if c.Limit != nil {
+				p.Limit = c.Limit
+			}
+		}
+		if c.LimitHit {
+			p.LimitHits++
+		}
+		p.Blocked += c.Blocked()
+	}
+
+	costByName := make(map[string]*provider.CostBreakdown)
+	for _, r := range costs {
+		if r.Status == provider.StatusOK && r.Cost != nil {
+			costByName[r.Name] = r.Cost
+		}
+	}
+
+	for _, snap := range snaps {
+		windows := peaks[snap.Name]
+		sort.Slice(windows, func(i, j int) bool { return windows[i].Metric < windows[j].Metric })
+		d.Subscriptions = append(d.Subscriptions, DigestEntry{
+			Snapshot: snap,
+			Windows:  windows,
+			Cost:     costByName[snap.Name],
+		})
+	}
+	return d
+}
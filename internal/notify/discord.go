@@ -0,0 +1,109 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var discordColors = map[string]int{
+	toneOK:       0x2EB67D,
+	toneInfo:     0x3498DB,
+	toneWarning:  0xF1C40F,
+	toneCritical: 0xE74C3C,
+}
+
+// discord posts embeds to a webhook. The event closing a thread edits the
+// original message instead of posting a new one.
+type discord struct {
+	config  Config
+	client  *http.Client
+	threads threads[string]
+}
+
+func (d *discord) useThreads(store *ThreadStore, scope string) {
+	d.threads.use(store, scope)
+}
+
+func newDiscord(c Config) (Notifier, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	if _, err := url.Parse(c.URL); err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	return &discord{config: c, client: &http.Client{Timeout: c.Timeout}}, nil
+}
+
+func (d *discord) message(ev Event) map[string]any {
+	c := newCard(ev)
+
+	var fields []map[string]any
+	for _, f := range c.Fields {
+		// Embeds take at most 25 fields.
+		if len(fields) == 25 {
+			break
+		}
+		fields = append(fields, map[string]any{"name": f.Name, "value": f.Value, "inline": false})
+	}
+
+	return map[string]any{
+		"username": "sub-mon",
+		"embeds": []any{map[string]any{
+			"title":     c.Title,
+			"color":     discordColors[c.Tone],
+			"fields":    fields,
+			"timestamp": ev.Time.UTC().Format(time.RFC3339),
+			"footer":    map[string]string{"text": "sub-mon"},
+		}},
+	}
+}
+
+// endpoint returns the webhook URL with extra path and query, keeping the
+// query of the configured URL (e.g. thread_id).
+func (d *discord) endpoint(path string, query url.Values) string {
+	u, _ := url.Parse(d.config.URL)
+	u.Path += path
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (d *discord) Notify(ctx context.Context, ev Event) error {
+	msg := d.message(ev)
+	key := threadKey(ev)
+
+	if closes(ev) {
+		if id, ok := d.threads.take(key); ok {
+			_, err := sendJSON(ctx, d.client, d.config, http.MethodPatch, d.endpoint("/messages/"+id, nil), nil, msg)
+			if err != nil {
+				// Keep the thread for the next closing event.
+				d.threads.set(key, id)
+			}
+			return err
+		}
+	}
+
+	body, err := postJSON(ctx, d.client, d.config, d.endpoint("", url.Values{"wait": {"true"}}), msg)
+	if err != nil {
+		return err
+	}
+	if closes(ev) {
+		return nil
+	}
+
+	var res struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.ID == "" {
+		return fmt.Errorf("unexpected response: %s", body)
+	}
+	d.threads.set(key, res.ID)
+	return nil
+}
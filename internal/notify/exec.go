@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxHookOutput caps the output of a failed hook quoted in its error.
+const maxHookOutput = 512
+
+// hook runs Command with the shell for every event. The event is passed as
+// JSON on stdin and its main fields as SUB_MON_* environment variables.
+// Commands are not idempotent in general, so unlike requests they are run
+// once and not retried.
+type hook struct {
+	config Config
+}
+
+func newExec(c Config) (Notifier, error) {
+	if strings.TrimSpace(c.Command) == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+	return &hook{config: c}, nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+func (h *hook) Notify(ctx context.Context, ev Event) error {
+	input, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := shellCommand(ctx, h.config.Command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(), hookEnv(ev)...)
+	// Do not wait for background processes the command left holding the
+	// output open.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", h.config.Timeout)
+		}
+		out := strings.TrimSpace(output.String())
+		if len(out) > maxHookOutput {
+			out = "..." + out[len(out)-maxHookOutput:]
+		}
+		if out != "" {
+			return fmt.Errorf("command failed: %w: %s", err, out)
+		}
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
+
+type envVar struct{ name, value string }
+
+// hookEnv describes ev as environment variables. Variables that do not apply
+// to the event are left unset.
+func hookEnv(ev Event) []string {
+	snap := ev.Snapshot
+	vars := []envVar{
+		{"SUB_MON_EVENT", string(ev.Kind)},
+		{"SUB_MON_SEVERITY", string(ev.Severity)},
+		{"SUB_MON_SUBSCRIPTION", ev.Subscription},
+		{"SUB_MON_PROVIDER", ev.Provider},
+		{"SUB_MON_SUMMARY", ev.Summary},
+		{"SUB_MON_STATUS", string(snap.Status)},
+		{"SUB_MON_PREVIOUS_STATUS", string(ev.PreviousStatus)},
+		{"SUB_MON_ERROR_CODE", string(snap.ErrorCode)},
+		{"SUB_MON_METRIC", ev.MetricName},
+		{"SUB_MON_URL", ev.URL},
+	}
+	if a := ev.Alert; a != nil {
+		vars = append(vars,
+			envVar{"SUB_MON_RULE", a.Rule},
+			envVar{"SUB_MON_VALUE", a.Value},
+		)
+	}
+	if m := ev.Metric(); m != nil {
+		if m.Amount.Used != nil {
+			vars = append(vars, envVar{"SUB_MON_USED", strconv.FormatFloat(*m.Amount.Used, 'f', -1, 64)})
+		}
+		if m.Amount.Limit != nil {
+			vars = append(vars, envVar{"SUB_MON_LIMIT", strconv.FormatFloat(*m.Amount.Limit, 'f', -1, 64)})
+		}
+		if m.Window.ResetsAt != nil {
+			vars = append(vars, envVar{"SUB_MON_RESETS_AT", m.Window.ResetsAt.UTC().Format(time.RFC3339)})
+		}
+	}
+
+	env := make([]string, 0, len(vars))
+	for _, v := range vars {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+	return env
+}
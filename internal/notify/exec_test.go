@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHook(t *testing.T, command string, timeout time.Duration) Notifier {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh")
+	}
+	n, err := New(Config{Name: "hook", Type: "exec", Command: command, Timeout: timeout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestHook_PassesEvent(t *testing.T) {
+	dir := t.TempDir()
+	n := newTestHook(t, `cd "`+dir+`" && cat > event.json && env | grep ^SUB_MON_ | sort > env.txt`, 0)
+
+	ev := testEvent()
+	ev.URL = "https://sub-mon.example.com/api/v1/usage?name=sub-a"
+	if err := n.Notify(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "event.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Event
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("stdin is not the event: %v", err)
+	}
+	if got.Kind != KindFiring || got.Alert == nil || got.Snapshot.Name != "sub-a" {
+		t.Errorf("unexpected event: %+v", got)
+	}
+
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"SUB_MON_EVENT=firing",
+		"SUB_MON_SUBSCRIPTION=sub-a",
+		"SUB_MON_METRIC=5h",
+		"SUB_MON_RULE=" + ev.Alert.Rule,
+		"SUB_MON_USED=95",
+		"SUB_MON_LIMIT=100",
+		"SUB_MON_URL=" + ev.URL,
+	} {
+		if !strings.Contains(string(env), want+"\n") {
+			t.Errorf("missing %s in:\n%s", want, env)
+		}
+	}
+	if strings.Contains(string(env), "SUB_MON_PREVIOUS_STATUS") {
+		t.Errorf("expected unset variables for fields the event lacks:\n%s", env)
+	}
+}
+
+func TestHook_Failure(t *testing.T) {
+	n := newTestHook(t, `echo "model switch failed" >&2; exit 3`, 0)
+	err := n.Notify(context.Background(), testEvent())
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") || !strings.Contains(err.Error(), "model switch failed") {
+		t.Errorf("expected the exit status and output, got %v", err)
+	}
+}
+
+func TestHook_Timeout(t *testing.T) {
+	n := newTestHook(t, `sleep 5`, 100*time.Millisecond)
+	start := time.Now()
+	err := n.Notify(context.Background(), testEvent())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("hook was not killed, took %s", elapsed)
+	}
+}
+
+func TestNewExec_MissingCommand(t *testing.T) {
+	if _, err := New(Config{Name: "hook", Type: "exec"}); err == nil {
+		t.Error("expected an error without a command")
+	}
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/format"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// Tones decide the colour of rich messages. Resolved alerts and recovered
+// subscriptions are ok, everything else follows the event's severity.
+const (
+	toneOK       = "ok"
+	toneInfo     = "info"
+	toneWarning  = "warning"
+	toneCritical = "critical"
+)
+
+func tone(ev Event) string {
+	if closes(ev) {
+		return toneOK
+	}
+	switch ev.Severity {
+	case alert.SeverityCritical:
+		return toneCritical
+	case alert.SeverityInfo:
+		return toneInfo
+	default:
+		return toneWarning
+	}
+}
+
+// threadKey groups an event with the events that continue it: an alert's
+// firing and resolved events, a subscription's failure and recovery.
+func threadKey(ev Event) string {
+	if ev.Alert != nil {
+		return "alert/" + ev.Alert.Key()
+	}
+	if ev.Kind == KindReset {
+		return "reset/" + ev.Subscription + "/" + ev.MetricName
+	}
+	return "status/" + ev.Subscription
+}
+
+// closes reports whether ev ends what earlier events of its thread opened.
+func closes(ev Event) bool {
+	return ev.Kind == KindResolved || (ev.Kind == KindStatus && ev.Snapshot.Status == provider.StatusOK)
+}
+
+// field is a labelled line of a rich message.
+type field struct {
+	Name  string
+	Value string
+}
+
+// card is the content shared by the rich message formats: a title and the
+// subscription, metric progress, reset time and status as fields.
+type card struct {
+	Title  string
+	Tone   string
+	Fields []field
+}
+
+func newCard(ev Event) card {
+	c := card{Title: title(ev), Tone: tone(ev)}
+	snap := ev.Snapshot
+
+	sub := fmt.Sprintf("%s (%s)", ev.Subscription, ev.Provider)
+	if snap.Plan != nil && snap.Plan.Name != "" {
+		sub += ", " + snap.Plan.Name
+	}
+	c.Fields = append(c.Fields, field{"Subscription", sub})
+
+	if a := ev.Alert; a != nil {
+		c.Fields = append(c.Fields, field{"Rule", fmt.Sprintf("%s (value %s)", a.When, a.Value)})
+	}
+
+	metrics := snap.Metrics
+	if m := ev.Metric(); m != nil {
+		metrics = []provider.UsageMetric{*m}
+	} else if ev.Alert != nil {
+		metrics = nil
+	}
+	for _, m := range metrics {
+		c.Fields = append(c.Fields, field{m.Name, formatMetric(m, ev.Time)})
+	}
+
+	if ev.Kind == KindStatus || snap.Status != provider.StatusOK {
+		status := string(snap.Status)
+		if ev.PreviousStatus != "" {
+			status = fmt.Sprintf("%s → %s", ev.PreviousStatus, snap.Status)
+		}
+		if hint := snap.ErrorCode.Hint(); hint != "" {
+			status += ": " + hint
+		} else if snap.Status != provider.StatusOK && snap.Error != "" {
+			status += ": " + snap.Error
+		}
+		c.Fields = append(c.Fields, field{"Status", status})
+	}
+
+	return c
+}
+
+func title(ev Event) string {
+	switch ev.Kind {
+	case KindFiring, KindResolved:
+		a := ev.Alert
+		target := a.Subscription
+		if a.Metric != "" {
+			target += " " + a.Metric
+		}
+		return fmt.Sprintf("%s %s: %s", a.Rule, ev.Kind, target)
+	case KindStatus:
+		if ev.Snapshot.Status == provider.StatusOK {
+			return fmt.Sprintf("%s recovered", ev.Subscription)
+		}
+		return fmt.Sprintf("%s is %s", ev.Subscription, ev.Snapshot.Status)
+	case KindReset:
+		return fmt.Sprintf("%s %s window reset", ev.Subscription, ev.MetricName)
+	}
+	return ev.Summary
+}
+
+// text renders the card as plain text, one field per line.
+func (c card) text() string {
+	var b strings.Builder
+	b.WriteString(c.Title)
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", f.Name, f.Value)
+	}
+	return b.String()
+}
+
+// formatMetric is the metric's progress and reset time on one line, e.g.
+// "[#########-] 95% (95 / 100), resets in 2h".
+func formatMetric(m provider.UsageMetric, now time.Time) string {
+	a := m.Amount
+	var line string
+	switch {
+	case a.Used != nil && a.Limit != nil && *a.Limit > 0:
+		percent := *a.Used / *a.Limit * 100
+		line = fmt.Sprintf("%s %.0f%% (%s / %s)", format.ProgressBar(percent), percent, format.Number(*a.Used), format.Number(*a.Limit))
+	case a.Used != nil:
+		line = strings.TrimSpace(format.Number(*a.Used) + " " + a.Unit)
+	case a.Remaining != nil:
+		line = strings.TrimSpace(format.Number(*a.Remaining) + " " + a.Unit + " remaining")
+	default:
+		line = "N/A"
+	}
+
+	if reset := format.Reset(m.Window.ResetsAt, now); reset != "" {
+		line += ", " + reset
+		if r := m.Window.ResetsAt; r.After(now) {
+			line += " (" + r.UTC().Format("Jan 2 15:04 MST") + ")"
+		}
+	}
+	return line
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestNewCard(t *testing.T) {
+	ev := testEvent()
+	ev.Snapshot.Plan = &provider.PlanInfo{Name: "Pro"}
+	ev.Snapshot.Metrics[0].Window.ResetsAt = provider.Ptr(ev.Time.Add(2*time.Hour + 30*time.Minute))
+
+	c := newCard(ev)
+	if c.Title != "high firing: sub-a 5h" || c.Tone != toneWarning {
+		t.Errorf("unexpected title or tone: %q %q", c.Title, c.Tone)
+	}
+
+	want := []field{
+		{"Subscription", "sub-a (kimi), Pro"},
+		{"Rule", "used_percent >= 90 (value 95)"},
+		{"5h", "[#########-] 95% (95 / 100), resets in 2h30m (Jan 1 14:30 UTC)"},
+	}
+	if len(c.Fields) != len(want) {
+		t.Fatalf("expected %d fields, got %+v", len(want), c.Fields)
+	}
+	for i, f := range want {
+		if c.Fields[i] != f {
+			t.Errorf("field %d: got %+v, want %+v", i, c.Fields[i], f)
+		}
+	}
+}
+
+func TestNewCard_Status(t *testing.T) {
+	snap := provider.UsageSnapshot{Name: "sub-a", ProviderID: "kimi", Status: provider.StatusUnauthorized, ErrorCode: provider.ErrorCodeUnauthorized, Metrics: []provider.UsageMetric{}}
+	c := newCard(StatusEvent(provider.StatusOK, snap, time.Now()))
+
+	if c.Title != "sub-a is unauthorized" || c.Tone != toneCritical {
+		t.Errorf("unexpected title or tone: %q %q", c.Title, c.Tone)
+	}
+	last := c.Fields[len(c.Fields)-1]
+	if last.Name != "Status" || last.Value != "ok → unauthorized: "+provider.ErrorCodeUnauthorized.Hint() {
+		t.Errorf("unexpected status field %+v", last)
+	}
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// maxResponseSize bounds how much of a response body is read.
+const maxResponseSize = 1 << 20
+
+// send performs the request built by newReq and returns the response body.
+// Network errors, 429 and 5xx responses are retried according to policy;
+// newReq is called for every attempt so that the body can be sent again.
+func send(ctx context.Context, client *http.Client, policy provider.RetryPolicy, newReq func(ctx context.Context) (*http.Request, error)) ([]byte, error) {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		body, err := sendOnce(ctx, client, newReq)
+		if err == nil {
+			return body, nil
+		}
+		if !provider.ErrorCodeOf(err).Retryable() {
+			return nil, err
+		}
+		if attempt == attempts {
+			if attempt > 1 {
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return nil, err
+		}
+
+		timer := time.NewTimer(policy.Delay(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func sendOnce(ctx context.Context, client *http.Client, newReq func(ctx context.Context) (*http.Request, error)) ([]byte, error) {
+	req, err := newReq(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, provider.NewHTTPError(resp)
+	}
+	return body, nil
+}
@@ -0,0 +1,392 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+type Kind string
+
+const (
+	// KindFiring and KindResolved report alert rules crossing and
+	// recovering from their threshold.
+	KindFiring   Kind = "firing"
+	KindResolved Kind = "resolved"
+	// KindStatus reports a subscription whose status changed between two
+	// refreshes, e.g. from ok to unauthorized.
+	KindStatus Kind = "status"
+	// KindReset reports that a metric's quota window started over. Notifiers
+	// only receive it when they list it in their events.
+	KindReset Kind = "reset"
+)
+
+var (
+	kinds        = []Kind{KindFiring, KindResolved, KindStatus, KindReset}
+	defaultKinds = []Kind{KindFiring, KindResolved, KindStatus}
+)
+
+// Event is what notifiers send. Snapshot is the subscription's snapshot as
+// served after the refresh that produced the event, URL links to it on the
+// API server when settings.public_url is set.
+type Event struct {
+	Kind           Kind                   `json:"kind"`
+	Time           time.Time              `json:"time"`
+	Severity       alert.Severity         `json:"severity"`
+	Subscription   string                 `json:"subscription"`
+	Provider       string                 `json:"provider"`
+	Summary        string                 `json:"summary"`
+	Alert          *alert.Alert           `json:"alert,omitempty"`
+	MetricName     string                 `json:"metric,omitempty"`
+	PreviousStatus provider.Status        `json:"previous_status,omitempty"`
+	Snapshot       provider.UsageSnapshot `json:"snapshot"`
+	URL            string                 `json:"url,omitempty"`
+}
+
+// AlertEvent wraps an alert transition.
+func AlertEvent(ev alert.Event, snap provider.UsageSnapshot, now time.Time) Event {
+	a := ev.Alert
+	return Event{
+		Kind:         Kind(ev.State),
+		Time:         now,
+		Severity:     a.Severity,
+		Subscription: a.Subscription,
+		Provider:     a.Provider,
+		Summary:      fmt.Sprintf("[%s] %s", ev.State, a.Summary()),
+		Alert:        &a,
+		MetricName:   a.Metric,
+		Snapshot:     snap,
+	}
+}
+
+// StatusEvent reports that snap's status differs from prev. Recovering to ok
+// is info, losing credentials is critical and any other failure a warning.
+func StatusEvent(prev provider.Status, snap provider.UsageSnapshot, now time.Time) Event {
+	severity := alert.SeverityWarning
+	switch snap.Status {
+	case provider.StatusOK:
+		severity = alert.SeverityInfo
+	case provider.StatusUnauthorized:
+		severity = alert.SeverityCritical
+	}
+
+	summary := fmt.Sprintf("%s: status changed from %s to %s", snap.Name, prev, snap.Status)
+	if snap.Status != provider.StatusOK && snap.Error != "" {
+		summary += ": " + snap.Error
+	}
+
+	return Event{
+		Kind:           KindStatus,
+		Time:           now,
+		Severity:       severity,
+		Subscription:   snap.Name,
+		Provider:       snap.ProviderID,
+		Summary:        summary,
+		PreviousStatus: prev,
+		Snapshot:       snap,
+	}
+}
+
+// ResetEvents reports the metrics of snap whose window reset since prev, the
+// previously served snapshot of the subscription. Failed and stale snapshots
+// carry no new values and yield nothing.
+func ResetEvents(prev, snap provider.UsageSnapshot, now time.Time) []Event {
+	if snap.Status != provider.StatusOK {
+		return nil
+	}
+	// prev may be the last good values served while fetches were failing,
+	// which are still what the window looked like before.
+	prev.Stale = false
+	before := make(map[string]history.Sample)
+	for _, s := range history.SamplesFromSnapshot(prev) {
+		before[s.Metric] = s
+	}
+
+	var events []Event
+	for _, cur := range history.SamplesFromSnapshot(snap) {
+		old, ok := before[cur.Metric]
+		if !ok || len(history.Resets([]history.Sample{old, cur})) == 0 {
+			continue
+		}
+		events = append(events, Event{
+			Kind:         KindReset,
+			Time:         now,
+			Severity:     alert.SeverityInfo,
+			Subscription: snap.Name,
+			Provider:     snap.ProviderID,
+			Summary:      fmt.Sprintf("%s: %s window reset", snap.Name, cur.Metric),
+			MetricName:   cur.Metric,
+			Snapshot:     snap,
+		})
+	}
+	return events
+}
+
+// Metric returns the snapshot metric the event refers to, or nil for events
+// that are not about a single metric.
+func (e Event) Metric() *provider.UsageMetric {
+	if e.MetricName == "" {
+		return nil
+	}
+	for i := range e.Snapshot.Metrics {
+		if e.Snapshot.Metrics[i].Name == e.MetricName {
+			return &e.Snapshot.Metrics[i]
+		}
+	}
+	return nil
+}
+
+// Notifier delivers events to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, ev Event) error
+}
+
+var statuses = []provider.Status{
+	provider.StatusOK, provider.StatusError, provider.StatusUnauthorized,
+	provider.StatusRateLimited, provider.StatusUnavailable, provider.StatusUnsupported,
+}
+
+var severityRank = map[alert.Severity]int{
+	alert.SeverityInfo:     1,
+	alert.SeverityWarning:  2,
+	alert.SeverityCritical: 3,
+}
+
+// Config is a notifier from the config file. Type selects the notifier,
+// Events, Statuses and MinSeverity select the events it receives; they
+// default to all but reset events. Statuses limits status events to changes
+// to one of the listed statuses. Timeout applies to every attempt, Retry to
+// transient failures.
+type Config struct {
+	Name            string               `yaml:"name" mapstructure:"name"`
+	Type            string               `yaml:"type" mapstructure:"type"`
+	URL             string               `yaml:"url" mapstructure:"url"`
+	Headers         map[string]string    `yaml:"headers,omitempty" mapstructure:"headers"`
+	Template        string               `yaml:"template,omitempty" mapstructure:"template"`
+	Secret          string               `yaml:"secret,omitempty" mapstructure:"secret"`
+	Token           string               `yaml:"token,omitempty" mapstructure:"token"`
+	Channel         string               `yaml:"channel,omitempty" mapstructure:"channel"`
+	ClickURL        string               `yaml:"click_url,omitempty" mapstructure:"click_url"`
+	SignatureHeader string               `yaml:"signature_header,omitempty" mapstructure:"signature_header"`
+	Timeout         time.Duration        `yaml:"timeout,omitempty" mapstructure:"timeout"`
+	Retry           provider.RetryPolicy `yaml:"retry,omitempty" mapstructure:"retry"`
+	Command         string               `yaml:"command,omitempty" mapstructure:"command"`
+	Events          []Kind               `yaml:"events,omitempty" mapstructure:"events"`
+	Statuses        []provider.Status    `yaml:"statuses,omitempty" mapstructure:"statuses"`
+	MinSeverity     alert.Severity       `yaml:"min_severity,omitempty" mapstructure:"min_severity"`
+	SMTP            *SMTPConfig          `yaml:"smtp,omitempty" mapstructure:"smtp"`
+	Digest          *DigestConfig        `yaml:"digest,omitempty" mapstructure:"digest"`
+}
+
+const defaultTimeout = 10 * time.Second
+
+func (c Config) withDefaults() Config {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	def := provider.DefaultRetryPolicy()
+	if c.Retry.MaxAttempts <= 0 {
+		c.Retry.MaxAttempts = def.MaxAttempts
+	}
+	if c.Retry.BaseDelay <= 0 {
+		c.Retry.BaseDelay = def.BaseDelay
+	}
+	if c.Retry.MaxDelay <= 0 {
+		c.Retry.MaxDelay = def.MaxDelay
+	}
+	return c
+}
+
+// Validate checks the common settings and that the notifier can be built.
+func (c Config) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("notifier needs a name")
+	}
+	for _, k := range c.Events {
+		if !slices.Contains(kinds, k) {
+			return fmt.Errorf("notifier %q: unknown event %q", c.Name, k)
+		}
+	}
+	for _, st := range c.Statuses {
+		if !slices.Contains(statuses, st) {
+			return fmt.Errorf("notifier %q: unknown status %q", c.Name, st)
+		}
+	}
+	if c.MinSeverity != "" && severityRank[c.MinSeverity] == 0 {
+		return fmt.Errorf("notifier %q: unknown severity %q", c.Name, c.MinSeverity)
+	}
+	n, err := New(c)
+	if err != nil {
+		return fmt.Errorf("notifier %q: %w", c.Name, err)
+	}
+	if c.Digest != nil {
+		if _, ok := n.(DigestNotifier); !ok {
+			return fmt.Errorf("notifier %q: %s notifiers do not send digests", c.Name, c.Type)
+		}
+		if err := c.Digest.Validate(); err != nil {
+			return fmt.Errorf("notifier %q: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// accepts reports whether the notifier receives ev. Notifiers with a digest
+// only send digests unless their events are listed.
+func (c Config) accepts(ev Event) bool {
+	events := c.Events
+	if len(events) == 0 {
+		if c.Digest != nil {
+			return false
+		}
+		events = defaultKinds
+	}
+	if !slices.Contains(events, ev.Kind) {
+		return false
+	}
+	if ev.Kind == KindStatus && len(c.Statuses) > 0 && !slices.Contains(c.Statuses, ev.Snapshot.Status) {
+		return false
+	}
+	return severityRank[ev.Severity] >= severityRank[c.MinSeverity]
+}
+
+// New builds the notifier selected by c.Type.
+func New(c Config) (Notifier, error) {
+	c = c.withDefaults()
+	switch c.Type {
+	case "webhook":
+		return newWebhook(c)
+	case "feishu", "lark", "dingtalk", "wecom":
+		return newBot(c)
+	case "slack":
+		return newSlack(c)
+	case "discord":
+		return newDiscord(c)
+	case "smtp":
+		return newMailer(c)
+	case "ntfy":
+		return newNtfy(c)
+	case "gotify":
+		return newGotify(c)
+	case "exec":
+		return newExec(c)
+	case "":
+		return nil, fmt.Errorf("missing type")
+	default:
+		return nil, fmt.Errorf("unknown type %q", c.Type)
+	}
+}
+
+// route is a notifier with the events waiting to be sent to it, queued by
+// thread key. A key is present while a goroutine sends its queue.
+type route struct {
+	config   Config
+	notifier Notifier
+	mu       sync.Mutex
+	queues   map[string][]Event
+}
+
+// Dispatcher sends events to every notifier that accepts them. Sending is
+// asynchronous so that a slow destination does not hold up refreshes. Events
+// of the same thread reach a notifier one at a time and in order, so that
+// the event closing a thread finds the message the opening one sent.
+type Dispatcher struct {
+	routes []*route
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher builds the configured notifiers. Configs are validated by
+// config.Load, one that fails to build is skipped with a warning. Notifiers
+// that continue threads keep them in threads, or in memory when it is nil.
+func NewDispatcher(configs []Config, threads *ThreadStore) *Dispatcher {
+	d := &Dispatcher{}
+	for _, c := range configs {
+		n, err := New(c)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notifier %q disabled: %v\n", c.Name, err)
+			continue
+		}
+		if t, ok := n.(threaded); ok && threads != nil {
+			t.useThreads(threads, c.Name)
+		}
+		d.routes = append(d.routes, &route{config: c, notifier: n})
+	}
+	return d
+}
+
+// Dispatch sends ev to the accepting notifiers in the background.
+func (d *Dispatcher) Dispatch(ev Event) {
+	key := threadKey(ev)
+	for _, r := range d.routes {
+		if !r.config.accepts(ev) {
+			continue
+		}
+
+		r.mu.Lock()
+		if r.queues == nil {
+			r.queues = make(map[string][]Event)
+		}
+		queue, sending := r.queues[key]
+		r.queues[key] = append(queue, ev)
+		r.mu.Unlock()
+		if sending {
+			continue
+		}
+
+		d.wg.Add(1)
+		go func(r *route) {
+			defer d.wg.Done()
+			r.drain(key)
+		}(r)
+	}
+}
+
+// drain sends the queued events of a thread until none is left.
+func (r *route) drain(key string) {
+	for {
+		r.mu.Lock()
+		queue := r.queues[key]
+		if len(queue) == 0 {
+			delete(r.queues, key)
+			r.mu.Unlock()
+			return
+		}
+		ev := queue[0]
+		r.queues[key] = queue[1:]
+		r.mu.Unlock()
+
+		if err := r.notifier.Notify(context.Background(), ev); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notifier %q failed: %v\n", r.config.Name, err)
+		}
+	}
+}
+
+// ScheduledDigest is a notifier with a digest schedule.
+type ScheduledDigest struct {
+	Name     string
+	Config   DigestConfig
+	Notifier DigestNotifier
+}
+
+// Digests returns the notifiers that send digests.
+func (d *Dispatcher) Digests() []ScheduledDigest {
+	var out []ScheduledDigest
+	for _, r := range d.routes {
+		if dn, ok := r.notifier.(DigestNotifier); ok && r.config.Digest != nil {
+			out = append(out, ScheduledDigest{Name: r.config.Name, Config: *r.config.Digest, Notifier: dn})
+		}
+	}
+	return out
+}
+
+// Wait blocks until all dispatched events have been delivered or given up.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
@@ -0,0 +1,158 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{Name: "hook", Type: "webhook", URL: "https://example.com", Events: []Kind{KindFiring}, MinSeverity: alert.SeverityWarning}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := []Config{
+		{Type: "webhook", URL: "https://example.com"},
+		{Name: "hook", URL: "https://example.com"},
+		{Name: "hook", Type: "pager", URL: "https://example.com"},
+		{Name: "hook", Type: "webhook"},
+		{Name: "hook", Type: "webhook", URL: "https://example.com", Template: "{{.Summary"},
+		{Name: "hook", Type: "webhook", URL: "https://example.com", Events: []Kind{"expired"}},
+		{Name: "hook", Type: "webhook", URL: "https://example.com", Statuses: []provider.Status{"broken"}},
+		{Name: "hook", Type: "webhook", URL: "https://example.com", MinSeverity: "fatal"},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestStatusEvent(t *testing.T) {
+	snap := provider.UsageSnapshot{Name: "sub-a", ProviderID: "kimi", Status: provider.StatusUnauthorized, Error: "credentials rejected"}
+	ev := StatusEvent(provider.StatusOK, snap, time.Now())
+	if ev.Kind != KindStatus || ev.Severity != alert.SeverityCritical || ev.PreviousStatus != provider.StatusOK {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.Summary != "sub-a: status changed from ok to unauthorized: credentials rejected" {
+		t.Errorf("unexpected summary %q", ev.Summary)
+	}
+
+	snap.Status = provider.StatusOK
+	if ev := StatusEvent(provider.StatusUnauthorized, snap, time.Now()); ev.Severity != alert.SeverityInfo {
+		t.Errorf("expected recovery to be info, got %s", ev.Severity)
+	}
+}
+
+func TestResetEvents(t *testing.T) {
+	now := time.Date(2026, 2, 15, 13, 0, 0, 0, time.UTC)
+	resets := now.Add(-time.Hour)
+	snapshot := func(used float64, resetsAt time.Time) provider.UsageSnapshot {
+		return provider.UsageSnapshot{
+			Name: "sub-a", ProviderID: "kimi", Status: provider.StatusOK, Timestamp: now,
+			Metrics: []provider.UsageMetric{
+				{Name: "5h", Amount: provider.UsageAmount{Used: provider.Ptr(used), Limit: provider.Ptr(100.0)}, Window: provider.UsageWindow{ID: "5h", ResetsAt: provider.Ptr(resetsAt)}},
+				{Name: "weekly", Amount: provider.UsageAmount{Used: provider.Ptr(used)}},
+			},
+		}
+	}
+
+	prev := snapshot(100, resets)
+	prev.Timestamp = resets.Add(-time.Minute)
+	prev.Stale = true
+	events := ResetEvents(prev, snapshot(3, resets.Add(5*time.Hour)), now)
+	if len(events) != 2 || events[0].Kind != KindReset || events[0].MetricName != "5h" || events[1].MetricName != "weekly" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if m := events[0].Metric(); m == nil || *m.Amount.Used != 3 {
+		t.Errorf("expected the reset metric, got %+v", m)
+	}
+
+	if events := ResetEvents(prev, snapshot(100, resets.Add(30*time.Second)), now); len(events) != 0 {
+		t.Errorf("expected no reset for drifting reset times, got %+v", events)
+	}
+	failed := snapshot(3, resets.Add(5*time.Hour))
+	failed.Status = provider.StatusError
+	if events := ResetEvents(prev, failed, now); len(events) != 0 {
+		t.Errorf("expected no reset for a failed snapshot, got %+v", events)
+	}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) Notify(ctx context.Context, ev Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	all, critical, statusOnly, failures, resets := &recorder{}, &recorder{}, &recorder{}, &recorder{}, &recorder{}
+	d := &Dispatcher{routes: []*route{
+		{config: Config{Name: "all"}, notifier: all},
+		{config: Config{Name: "critical", MinSeverity: alert.SeverityCritical}, notifier: critical},
+		{config: Config{Name: "status", Events: []Kind{KindStatus}}, notifier: statusOnly},
+		{config: Config{Name: "failures", Events: []Kind{KindStatus}, Statuses: []provider.Status{provider.StatusError}}, notifier: failures},
+		{config: Config{Name: "resets", Events: []Kind{KindReset}}, notifier: resets},
+	}}
+
+	d.Dispatch(testEvent())
+	d.Dispatch(StatusEvent(provider.StatusOK, provider.UsageSnapshot{Name: "sub-a", Status: provider.StatusUnauthorized}, time.Now()))
+	d.Dispatch(Event{Kind: KindReset, Severity: alert.SeverityInfo, Subscription: "sub-a", MetricName: "5h"})
+	d.Wait()
+
+	if len(all.events) != 2 {
+		t.Errorf("expected both events, got %d", len(all.events))
+	}
+	if len(critical.events) != 1 || critical.events[0].Kind != KindStatus {
+		t.Errorf("expected only the critical status event, got %+v", critical.events)
+	}
+	if len(statusOnly.events) != 1 || statusOnly.events[0].Kind != KindStatus {
+		t.Errorf("expected only the status event, got %+v", statusOnly.events)
+	}
+	if len(failures.events) != 0 {
+		t.Errorf("expected no event for an unlisted status, got %+v", failures.events)
+	}
+	if len(resets.events) != 1 || resets.events[0].Kind != KindReset {
+		t.Errorf("expected only the reset event, got %+v", resets.events)
+	}
+}
+
+// slowRecorder takes a while to deliver firing events.
+type slowRecorder struct {
+	recorder
+}
+
+func (r *slowRecorder) Notify(ctx context.Context, ev Event) error {
+	if ev.Kind == KindFiring {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return r.recorder.Notify(ctx, ev)
+}
+
+func TestDispatcher_OrdersThreadEvents(t *testing.T) {
+	slow := &slowRecorder{}
+	d := &Dispatcher{routes: []*route{{config: Config{Name: "slow"}, notifier: slow}}}
+
+	d.Dispatch(testEvent())
+	resolved := testEvent()
+	resolved.Kind = KindResolved
+	d.Dispatch(resolved)
+	d.Wait()
+
+	if len(slow.events) != 2 || slow.events[0].Kind != KindFiring || slow.events[1].Kind != KindResolved {
+		t.Errorf("expected firing before resolved, got %+v", slow.events)
+	}
+	if len(d.routes[0].queues) != 0 {
+		t.Errorf("expected the drained queue to be removed, got %v", d.routes[0].queues)
+	}
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Priorities by tone. ntfy uses 1 (min) to 5 (urgent), Gotify 0 to 10 where
+// clients alert loudly from 8.
+var (
+	ntfyPriorities = map[string]int{
+		toneOK:       2,
+		toneInfo:     3,
+		toneWarning:  4,
+		toneCritical: 5,
+	}
+	gotifyPriorities = map[string]int{
+		toneOK:       2,
+		toneInfo:     4,
+		toneWarning:  6,
+		toneCritical: 8,
+	}
+	ntfyTags = map[string][]string{
+		toneOK:       {"white_check_mark"},
+		toneInfo:     {"information_source"},
+		toneWarning:  {"warning"},
+		toneCritical: {"rotating_light"},
+	}
+)
+
+// clicker resolves the link opened when a push notification is tapped: the
+// click_url template executed with the event, or the event's link to the API
+// server, which is empty without settings.public_url.
+type clicker struct {
+	template *template.Template
+}
+
+func newClicker(c Config) (clicker, error) {
+	if c.ClickURL == "" {
+		return clicker{}, nil
+	}
+	tmpl, err := template.New("click_url").Funcs(template.FuncMap{"query": url.QueryEscape}).Parse(c.ClickURL)
+	if err != nil {
+		return clicker{}, fmt.Errorf("invalid click_url: %w", err)
+	}
+	return clicker{template: tmpl}, nil
+}
+
+func (c clicker) url(ev Event) (string, error) {
+	if c.template == nil {
+		return ev.URL, nil
+	}
+	var buf bytes.Buffer
+	if err := c.template.Execute(&buf, ev); err != nil {
+		return "", fmt.Errorf("failed to render click_url: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// pushMessage is the body of a push notification: the card without its
+// title, which push services show separately.
+func pushMessage(c card) string {
+	lines := make([]string, 0, len(c.Fields))
+	for _, f := range c.Fields {
+		lines = append(lines, f.Name+": "+f.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ntfy publishes to a topic of an ntfy server. URL is the topic URL, e.g.
+// https://ntfy.sh/my-topic; Token is an optional access token.
+type ntfy struct {
+	config  Config
+	client  *http.Client
+	clicker clicker
+	server  string
+	topic   string
+}
+
+func newNtfy(c Config) (Notifier, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("url must be a topic URL like https://ntfy.sh/my-topic")
+	}
+	topic := path.Base(u.Path)
+	if topic == "/" || topic == "." {
+		return nil, fmt.Errorf("url must be a topic URL like https://ntfy.sh/my-topic")
+	}
+	u.Path = strings.TrimSuffix(path.Dir(u.Path), "/")
+
+	clicker, err := newClicker(c)
+	if err != nil {
+		return nil, err
+	}
+	return &ntfy{
+		config:  c,
+		client:  &http.Client{Timeout: c.Timeout},
+		clicker: clicker,
+		server:  u.String(),
+		topic:   topic,
+	}, nil
+}
+
+// Notify publishes ev as JSON, which unlike the header based API allows
+// titles in any language.
+func (n *ntfy) Notify(ctx context.Context, ev Event) error {
+	c := newCard(ev)
+	click, err := n.clicker.url(ev)
+	if err != nil {
+		return err
+	}
+
+	msg := map[string]any{
+		"topic":    n.topic,
+		"title":    c.Title,
+		"message":  pushMessage(c),
+		"priority": ntfyPriorities[c.Tone],
+		"tags":     ntfyTags[c.Tone],
+	}
+	if click != "" {
+		msg["click"] = click
+	}
+
+	var headers map[string]string
+	if n.config.Token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + n.config.Token}
+	}
+	_, err = sendJSON(ctx, n.client, n.config, http.MethodPost, n.server, headers, msg)
+	return err
+}
+
+// gotify sends messages to a Gotify server with an application Token.
+type gotify struct {
+	config  Config
+	client  *http.Client
+	clicker clicker
+}
+
+func newGotify(c Config) (Notifier, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	if c.Token == "" {
+		return nil, fmt.Errorf("missing application token")
+	}
+	clicker, err := newClicker(c)
+	if err != nil {
+		return nil, err
+	}
+	return &gotify{config: c, client: &http.Client{Timeout: c.Timeout}, clicker: clicker}, nil
+}
+
+func (g *gotify) Notify(ctx context.Context, ev Event) error {
+	c := newCard(ev)
+	click, err := g.clicker.url(ev)
+	if err != nil {
+		return err
+	}
+
+	msg := map[string]any{
+		"title":    c.Title,
+		"message":  pushMessage(c),
+		"priority": gotifyPriorities[c.Tone],
+	}
+	if click != "" {
+		msg["extras"] = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": click}},
+		}
+	}
+
+	headers := map[string]string{"X-Gotify-Key": g.config.Token}
+	_, err = sendJSON(ctx, g.client, g.config, http.MethodPost, strings.TrimSuffix(g.config.URL, "/")+"/message", headers, msg)
+	return err
+}
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestNtfy(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return `{"id":"abc"}` })
+
+	n, err := New(Config{Name: "ntfy", Type: "ntfy", URL: srv.URL + "/sub/alerts", Token: "tk_1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := testEvent()
+	ev.URL = "https://sub-mon.example.com/api/v1/usage?name=sub-a"
+	if err := n.Notify(context.Background(), ev); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected one request, got %+v", reqs)
+	}
+	r := reqs[0]
+	if r.Method != http.MethodPost || r.Path != "/sub" || r.Auth != "Bearer tk_1" {
+		t.Errorf("unexpected request: %+v", r)
+	}
+	if r.Body["topic"] != "alerts" || r.Body["title"] != "high firing: sub-a 5h" || r.Body["priority"] != float64(4) || r.Body["click"] != ev.URL {
+		t.Errorf("unexpected body: %v", r.Body)
+	}
+
+	if _, err := New(Config{Name: "ntfy", Type: "ntfy", URL: "https://ntfy.sh"}); err == nil {
+		t.Error("expected an error for a URL without topic")
+	}
+}
+
+func TestNtfy_CriticalStatus(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return `{}` })
+
+	n, err := New(Config{Name: "ntfy", Type: "ntfy", URL: srv.URL + "/alerts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := provider.UsageSnapshot{Name: "sub-a", ProviderID: "kimi", Status: provider.StatusUnauthorized}
+	if err := n.Notify(context.Background(), StatusEvent(provider.StatusOK, snap, fixedNow())); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	r := requests()[0]
+	if r.Path != "/" || r.Auth != "" || r.Body["priority"] != float64(5) || r.Body["click"] != nil {
+		t.Errorf("unexpected request: %+v", r)
+	}
+}
+
+func TestGotify(t *testing.T) {
+	srv, requests := recordingServer(t, func(recordedRequest) string { return `{"id":1}` })
+
+	n, err := New(Config{
+		Name:     "gotify",
+		Type:     "gotify",
+		URL:      srv.URL + "/",
+		Token:    "app-token",
+		ClickURL: "http://nas.local:3456/api/v1/usage?name={{query .Subscription}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), resolvedEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	r := requests()[0]
+	if r.Path != "/message" || r.Header.Get("X-Gotify-Key") != "app-token" || r.Body["priority"] != float64(2) {
+		t.Errorf("unexpected request: %+v", r)
+	}
+	click := r.Body["extras"].(map[string]any)["client::notification"].(map[string]any)["click"].(map[string]any)["url"]
+	if click != "http://nas.local:3456/api/v1/usage?name=sub-a" {
+		t.Errorf("unexpected click URL %v", click)
+	}
+
+	if _, err := New(Config{Name: "gotify", Type: "gotify", URL: srv.URL}); err == nil {
+		t.Error("expected an error without token")
+	}
+}
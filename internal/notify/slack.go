@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultSlackAPI = "https://slack.com/api"
+
+var slackColors = map[string]string{
+	toneOK:       "#2EB67D",
+	toneInfo:     "#36C5F0",
+	toneWarning:  "#ECB22E",
+	toneCritical: "#E01E5A",
+}
+
+// slack posts Block Kit messages. With an incoming webhook URL every event
+// is a new message. With a bot Token and Channel it uses the Web API (URL
+// overrides its base), so that the event closing a thread edits the
+// original message to show it resolved and replies in its thread.
+type slack struct {
+	config  Config
+	client  *http.Client
+	threads threads[slackRef]
+}
+
+type slackRef struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+func (s *slack) useThreads(store *ThreadStore, scope string) {
+	s.threads.use(store, scope)
+}
+
+// slackResult is the response of chat.postMessage and chat.update.
+type slackResult struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+func newSlack(c Config) (Notifier, error) {
+	if c.Token != "" {
+		if c.Channel == "" {
+			return nil, fmt.Errorf("a token needs a channel")
+		}
+		if c.URL == "" {
+			c.URL = defaultSlackAPI
+		}
+	} else if c.URL == "" {
+		return nil, fmt.Errorf("missing url or token")
+	}
+	return &slack{config: c, client: &http.Client{Timeout: c.Timeout}}, nil
+}
+
+// message is the Block Kit content of ev. The blocks are wrapped in an
+// attachment, which is the only way to colour a message.
+func (s *slack) message(ev Event) map[string]any {
+	c := newCard(ev)
+
+	var fields []map[string]string
+	for _, f := range c.Fields {
+		// Section blocks take at most 10 fields.
+		if len(fields) == 10 {
+			break
+		}
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", f.Name, slackEscape(f.Value))})
+	}
+
+	blocks := []any{
+		map[string]any{"type": "header", "text": map[string]string{"type": "plain_text", "text": c.Title}},
+		map[string]any{"type": "section", "fields": fields},
+		map[string]any{"type": "context", "elements": []map[string]string{{
+			"type": "mrkdwn",
+			"text": fmt.Sprintf("sub-mon · <!date^%d^{date_short_pretty} {time}|%s>", ev.Time.Unix(), ev.Time.UTC().Format(time.RFC3339)),
+		}}},
+	}
+
+	return map[string]any{
+		"text":        ev.Summary,
+		"attachments": []any{map[string]any{"color": slackColors[c.Tone], "blocks": blocks}},
+	}
+}
+
+// slackEscape escapes the characters Slack treats as control sequences.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func (s *slack) Notify(ctx context.Context, ev Event) error {
+	msg := s.message(ev)
+	if s.config.Token == "" {
+		_, err := postJSON(ctx, s.client, s.config, s.config.URL, msg)
+		return err
+	}
+
+	key := threadKey(ev)
+	if closes(ev) {
+		if ref, ok := s.threads.take(key); ok {
+			update := map[string]any{"channel": ref.Channel, "ts": ref.TS}
+			for k, v := range msg {
+				update[k] = v
+			}
+			if _, err := s.call(ctx, "chat.update", update); err != nil {
+				// Keep the thread for the next closing event.
+				s.threads.set(key, ref)
+				return err
+			}
+			msg["channel"], msg["thread_ts"] = ref.Channel, ref.TS
+			_, err := s.call(ctx, "chat.postMessage", msg)
+			return err
+		}
+	}
+
+	msg["channel"] = s.config.Channel
+	res, err := s.call(ctx, "chat.postMessage", msg)
+	if err != nil {
+		return err
+	}
+	if !closes(ev) {
+		s.threads.set(key, slackRef{Channel: res.Channel, TS: res.TS})
+	}
+	return nil
+}
+
+// call invokes a Web API method, which reports failures as ok=false.
+func (s *slack) call(ctx context.Context, method string, payload map[string]any) (slackResult, error) {
+	var res slackResult
+	headers := map[string]string{"Authorization": "Bearer " + s.config.Token}
+	body, err := sendJSON(ctx, s.client, s.config, http.MethodPost, strings.TrimSuffix(s.config.URL, "/")+"/"+method, headers, payload)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return res, fmt.Errorf("unexpected response: %s", body)
+	}
+	if !res.OK {
+		return res, fmt.Errorf("%s failed: %s", method, res.Error)
+	}
+	return res, nil
+}
@@ -0,0 +1,434 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/format"
+)
+
+// TLS modes of an SMTP connection.
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// SMTPConfig is the mail server and envelope of an smtp notifier. TLS is
+// starttls (the default, on port 587), tls for implicit TLS (the default on
+// port 465) or none. Credentials are only sent over TLS or to localhost.
+type SMTPConfig struct {
+	Host     string   `yaml:"host" mapstructure:"host"`
+	Port     int      `yaml:"port,omitempty" mapstructure:"port"`
+	TLS      string   `yaml:"tls,omitempty" mapstructure:"tls"`
+	Username string   `yaml:"username,omitempty" mapstructure:"username"`
+	Password string   `yaml:"password,omitempty" mapstructure:"password"`
+	From     string   `yaml:"from" mapstructure:"from"`
+	To       []string `yaml:"to" mapstructure:"to"`
+}
+
+func (c SMTPConfig) withDefaults() SMTPConfig {
+	if c.Port == 0 {
+		c.Port = 587
+		if c.TLS == SMTPTLS {
+			c.Port = 465
+		}
+	}
+	if c.TLS == "" {
+		c.TLS = SMTPStartTLS
+		if c.Port == 465 {
+			c.TLS = SMTPTLS
+		}
+	}
+	return c
+}
+
+func (c SMTPConfig) validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("missing smtp host")
+	}
+	switch c.TLS {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return fmt.Errorf("smtp tls must be starttls, tls or none, got %q", c.TLS)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid smtp from %q: %w", c.From, err)
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("missing smtp recipients")
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid smtp recipient %q: %w", to, err)
+		}
+	}
+	return nil
+}
+
+// mailer sends events and digests as multipart text and HTML mails.
+type mailer struct {
+	config Config
+	smtp   SMTPConfig
+	// tlsConfig overrides the TLS settings, used by tests.
+	tlsConfig *tls.Config
+	now       func() time.Time
+}
+
+func newMailer(c Config) (Notifier, error) {
+	if c.SMTP == nil {
+		return nil, fmt.Errorf("missing smtp settings")
+	}
+	s := c.SMTP.withDefaults()
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &mailer{config: c, smtp: s, now: time.Now}, nil
+}
+
+func (m *mailer) Notify(ctx context.Context, ev Event) error {
+	c := newCard(ev)
+
+	var text, html bytes.Buffer
+	text.WriteString(c.text())
+	text.WriteString("\n\n" + ev.Summary + "\n")
+	if err := eventHTML.Execute(&html, c); err != nil {
+		return err
+	}
+	return m.send(ctx, "[sub-mon] "+c.Title, text.String(), html.String())
+}
+
+// SendDigest mails d.
+func (m *mailer) SendDigest(ctx context.Context, d Digest) error {
+	view := newDigestView(d)
+
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, view); err != nil {
+		return err
+	}
+	if err := digestHTML.Execute(&html, view); err != nil {
+		return err
+	}
+	return m.send(ctx, "[sub-mon] "+view.Title, text.String(), html.String())
+}
+
+// send delivers a mail, retrying network errors and temporary (4xx) SMTP
+// replies according to the retry policy.
+func (m *mailer) send(ctx context.Context, subject, text, html string) error {
+	msg, err := m.message(subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	attempts := max(m.config.Retry.MaxAttempts, 1)
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		lastErr = m.deliver(ctx, msg)
+		if lastErr == nil || attempt == attempts || !temporarySMTPError(lastErr) {
+			break
+		}
+
+		timer := time.NewTimer(m.config.Retry.Delay(attempt, lastErr))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return lastErr
+}
+
+func temporarySMTPError(err error) bool {
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		return tpErr.Code >= 400 && tpErr.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (m *mailer) tls() *tls.Config {
+	if m.tlsConfig != nil {
+		return m.tlsConfig
+	}
+	return &tls.Config{ServerName: m.smtp.Host}
+}
+
+func (m *mailer) deliver(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(m.smtp.Host, strconv.Itoa(m.smtp.Port))
+	dialer := &net.Dialer{Timeout: m.config.Timeout}
+
+	var conn net.Conn
+	var err error
+	if m.smtp.TLS == SMTPTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: m.tls()}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(m.config.Timeout))
+
+	c, err := smtp.NewClient(conn, m.smtp.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if m.smtp.TLS == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(m.tls()); err != nil {
+			return err
+		}
+	}
+	if m.smtp.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.smtp.Username, m.smtp.Password, m.smtp.Host)); err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(m.smtp.From)
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range m.smtp.To {
+		addr, _ := mail.ParseAddress(to)
+		if err := c.Rcpt(addr.Address); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds a multipart/alternative mail with a text and an HTML part.
+func (m *mailer) message(subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var id [12]byte
+	rand.Read(id[:])
+	from, _ := mail.ParseAddress(m.smtp.From)
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", m.smtp.From},
+		{"To", strings.Join(m.smtp.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", m.now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%x@%s>", id, domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h[0], h[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+var eventHTML = htmltemplate.Must(htmltemplate.New("event").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h2 style="color: {{.Color}}">{{.Title}}</h2>
+<table cellpadding="4">
+{{- range .Fields}}
+<tr><th align="left" valign="top">{{.Name}}</th><td><code>{{.Value}}</code></td></tr>
+{{- end}}
+</table>
+<p style="color: #888">sub-mon</p>
+</body></html>
+`))
+
+var mailColors = map[string]string{
+	toneOK:       "#2E7D32",
+	toneInfo:     "#1565C0",
+	toneWarning:  "#EF6C00",
+	toneCritical: "#C62828",
+}
+
+// Color is the HTML colour of the card's tone.
+func (c card) Color() string {
+	return mailColors[c.Tone]
+}
+
+type digestView struct {
+	Title         string
+	Period        string
+	Subscriptions []digestSubscription
+}
+
+type digestSubscription struct {
+	Name    string
+	Plan    string
+	Status  string
+	Metrics []field
+	Windows []digestWindow
+	Cost    string
+}
+
+type digestWindow struct {
+	Metric    string
+	Windows   int
+	Peak      string
+	LimitHits int
+	Blocked   string
+}
+
+func newDigestView(d Digest) digestView {
+	title := "Daily usage digest"
+	if d.Schedule == DigestWeekly {
+		title = "Weekly usage digest"
+	}
+	layout := "Jan 2 15:04"
+	v := digestView{
+		Title:  fmt.Sprintf("%s, %s", title, d.Period.End.Format("Jan 2")),
+		Period: fmt.Sprintf("%s – %s", d.Period.Start.Format(layout), d.Period.End.Format(layout+" MST")),
+	}
+
+	for _, e := range d.Subscriptions {
+		snap := e.Snapshot
+		s := digestSubscription{
+			Name:   fmt.Sprintf("%s (%s)", snap.Name, snap.ProviderID),
+			Plan:   "N/A",
+			Status: string(snap.Status),
+			Cost:   "N/A",
+		}
+		if snap.Plan != nil && snap.Plan.Name != "" {
+			s.Plan = snap.Plan.Name
+		}
+		if snap.Stale {
+			s.Status += " (stale)"
+		}
+		for _, m := range snap.Metrics {
+			s.Metrics = append(s.Metrics, field{m.Name, formatMetric(m, d.Period.End)})
+		}
+		for _, w := range e.Windows {
+			peak := format.Number(w.Peak)
+			if w.Limit != nil && *w.Limit > 0 {
+				peak = fmt.Sprintf("%s / %s (%.0f%%)", peak, format.Number(*w.Limit), w.Peak / *w.Limit * 100)
+			}
+			blocked := "-"
+			if w.Blocked > 0 {
+				blocked = format.ETA(w.Blocked)
+			}
+			s.Windows = append(s.Windows, digestWindow{
+				Metric:    w.Metric,
+				Windows:   w.Windows,
+				Peak:      peak,
+				LimitHits: w.LimitHits,
+				Blocked:   blocked,
+			})
+		}
+		if e.Cost != nil {
+			s.Cost = strings.TrimSpace(fmt.Sprintf("%.2f %s", e.Cost.Total, e.Cost.Currency))
+		}
+		v.Subscriptions = append(v.Subscriptions, s)
+	}
+	return v
+}
+
+var digestText = template.Must(template.New("digest").Parse(`{{.Title}}
+{{.Period}}
+{{range .Subscriptions}}
+{{.Name}}
+  Plan:   {{.Plan}}
+  Status: {{.Status}}
+  Cost:   {{.Cost}}
+{{- if .Metrics}}
+  Current usage:
+{{- range .Metrics}}
+    {{.Name}}: {{.Value}}
+{{- end}}
+{{- end}}
+{{- if .Windows}}
+  Peak per window:
+{{- range .Windows}}
+    {{.Metric}}: {{.Peak}}, {{.Windows}} window(s), limit hit {{.LimitHits}}x, blocked {{.Blocked}}
+{{- end}}
+{{- end}}
+{{end}}
+--
+sub-mon
+`))
+
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h2>{{.Title}}</h2>
+<p style="color: #888">{{.Period}}</p>
+{{- range .Subscriptions}}
+<h3>{{.Name}}</h3>
+<table cellpadding="4">
+<tr><th align="left">Plan</th><td>{{.Plan}}</td></tr>
+<tr><th align="left">Status</th><td>{{.Status}}</td></tr>
+<tr><th align="left">Cost</th><td>{{.Cost}}</td></tr>
+{{- range .Metrics}}
+<tr><th align="left" valign="top">{{.Name}}</th><td><code>{{.Value}}</code></td></tr>
+{{- end}}
+</table>
+{{- if .Windows}}
+<table cellpadding="4" border="1" style="border-collapse: collapse">
+<tr><th>Window</th><th>Peak</th><th>Windows</th><th>Limit hits</th><th>Blocked</th></tr>
+{{- range .Windows}}
+<tr><td>{{.Metric}}</td><td>{{.Peak}}</td><td align="right">{{.Windows}}</td><td align="right">{{.LimitHits}}</td><td align="right">{{.Blocked}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+<p style="color: #888">sub-mon</p>
+</body></html>
+`))
@@ -0,0 +1,351 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/history"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// received is what the fake SMTP server saw in one session.
+type received struct {
+	auth     string
+	from     string
+	to       []string
+	data     string
+	startTLS bool
+}
+
+// smtpServer is a minimal SMTP server. With startTLS it offers STARTTLS,
+// with implicit it expects TLS from the first byte.
+func smtpServer(t *testing.T, cert *tls.Certificate, startTLS, implicit bool) (port int, sessions <-chan received) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var tlsConfig *tls.Config
+	if cert != nil {
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{*cert}}
+	}
+	if implicit {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
+	out := make(chan received, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				out <- serveSMTP(conn, tlsConfig, startTLS)
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, out
+}
+
+func serveSMTP(conn net.Conn, tlsConfig *tls.Config, startTLS bool) received {
+	var r received
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	reply := func(s string) {
+		rw.WriteString(s + "\r\n")
+		rw.Flush()
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return r
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO":
+			if startTLS && !r.startTLS {
+				reply("250-localhost\r\n250 STARTTLS")
+			} else {
+				reply("250-localhost\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 go ahead")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return r
+			}
+			conn = tlsConn
+			rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+			r.startTLS = true
+		case "AUTH":
+			creds, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+			r.auth = string(creds)
+			reply("235 ok")
+		case "MAIL":
+			r.from = line
+			reply("250 ok")
+		case "RCPT":
+			r.to = append(r.to, line)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := rw.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			r.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return r
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// testCert returns a self-signed certificate for 127.0.0.1 and a pool that
+// trusts it.
+func testCert(t *testing.T) (*tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func testMailer(t *testing.T, s SMTPConfig) *mailer {
+	t.Helper()
+	s.Host = "127.0.0.1"
+	s.From = "sub-mon <sub-mon@example.com>"
+	s.To = []string{"ops@example.com", "Boss <boss@example.com>"}
+	n, err := New(Config{Name: "mail", Type: "smtp", SMTP: &s, Timeout: 5 * time.Second, Retry: fastRetry(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n.(*mailer)
+}
+
+// parts decodes the text and HTML parts of a received mail.
+func parts(t *testing.T, data string) (subject, text, html string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid mail: %v", err)
+	}
+	subject, _ = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(p)
+		if strings.HasPrefix(p.Header.Get("Content-Type"), "text/plain") {
+			text = string(body)
+		} else {
+			html = string(body)
+		}
+	}
+	return subject, text, html
+}
+
+func TestMailer_PlainWithAuth(t *testing.T) {
+	port, sessions := smtpServer(t, nil, false, false)
+	m := testMailer(t, SMTPConfig{Port: port, TLS: SMTPNone, Username: "user", Password: "pass"})
+
+	if err := m.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	r := <-sessions
+	if r.auth != "\x00user\x00pass" {
+		t.Errorf("unexpected auth %q", r.auth)
+	}
+	if r.from != "MAIL FROM:<sub-mon@example.com>" || len(r.to) != 2 || r.to[1] != "RCPT TO:<boss@example.com>" {
+		t.Errorf("unexpected envelope: %q %q", r.from, r.to)
+	}
+
+	subject, text, html := parts(t, r.data)
+	if subject != "[sub-mon] high firing: sub-a 5h" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	if !strings.Contains(text, "5h: [#########-] 95% (95 / 100)") {
+		t.Errorf("unexpected text part %q", text)
+	}
+	if !strings.Contains(html, "<h2 style=\"color: #EF6C00\">high firing: sub-a 5h</h2>") {
+		t.Errorf("unexpected html part %q", html)
+	}
+}
+
+func TestMailer_StartTLS(t *testing.T) {
+	cert, pool := testCert(t)
+	port, sessions := smtpServer(t, cert, true, false)
+	m := testMailer(t, SMTPConfig{Port: port, Username: "user", Password: "pass"})
+	m.tlsConfig = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+
+	if err := m.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if r := <-sessions; !r.startTLS || r.auth == "" || r.data == "" {
+		t.Errorf("expected an authenticated mail over STARTTLS, got %+v", r)
+	}
+}
+
+func TestMailer_ImplicitTLS(t *testing.T) {
+	cert, pool := testCert(t)
+	port, sessions := smtpServer(t, cert, false, true)
+	m := testMailer(t, SMTPConfig{Port: port, TLS: SMTPTLS})
+	m.tlsConfig = &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+
+	if err := m.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if r := <-sessions; r.data == "" {
+		t.Errorf("expected a mail over TLS, got %+v", r)
+	}
+}
+
+func TestMailer_StartTLSRequired(t *testing.T) {
+	port, _ := smtpServer(t, nil, false, false)
+	m := testMailer(t, SMTPConfig{Port: port})
+
+	if err := m.Notify(context.Background(), testEvent()); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("expected a STARTTLS error, got %v", err)
+	}
+}
+
+func TestSMTPConfigDefaults(t *testing.T) {
+	if c := (SMTPConfig{}).withDefaults(); c.Port != 587 || c.TLS != SMTPStartTLS {
+		t.Errorf("unexpected defaults %+v", c)
+	}
+	if c := (SMTPConfig{Port: 465}).withDefaults(); c.TLS != SMTPTLS {
+		t.Errorf("expected implicit TLS on 465, got %+v", c)
+	}
+	if c := (SMTPConfig{TLS: SMTPTLS}).withDefaults(); c.Port != 465 {
+		t.Errorf("expected port 465 for implicit TLS, got %+v", c)
+	}
+}
+
+func TestMailer_Digest(t *testing.T) {
+	port, sessions := smtpServer(t, nil, false, false)
+	m := testMailer(t, SMTPConfig{Port: port, TLS: SMTPNone})
+
+	end := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+	period := provider.TimePeriod{Start: end.AddDate(0, 0, -1), End: end}
+	snaps := []provider.UsageSnapshot{testEvent().Snapshot}
+	cycles := []history.Cycle{
+		{Subscription: "sub-a", Metric: "5h", Peak: 80, Limit: provider.Ptr(100.0)},
+		{Subscription: "sub-a", Metric: "5h", Peak: 100, Limit: provider.Ptr(100.0), LimitHit: true, BlockedSeconds: 5400},
+	}
+	costs := []provider.CostResult{{Name: "sub-a", Status: provider.StatusOK, Cost: &provider.CostBreakdown{Total: 12.5, Currency: "USD"}}}
+
+	d := NewDigest(DigestDaily, period, snaps, cycles, costs)
+	if err := m.SendDigest(context.Background(), d); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+
+	subject, text, html := parts(t, (<-sessions).data)
+	if subject != "[sub-mon] Daily usage digest, Jan 2" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	for _, want := range []string{"sub-a (kimi)", "Cost:   12.50 USD", "5h: 100 / 100 (100%), 2 window(s), limit hit 1x, blocked 1h30m"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in text part:\n%s", want, text)
+		}
+	}
+	if !strings.Contains(html, "<td>100 / 100 (100%)</td>") {
+		t.Errorf("unexpected html part %q", html)
+	}
+}
+
+func TestDigestConfigNext(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	// Thursday
+	now := time.Date(2026, 1, 1, 9, 30, 0, 0, loc)
+
+	tests := []struct {
+		config DigestConfig
+		want   time.Time
+	}{
+		{DigestConfig{Schedule: DigestDaily}, time.Date(2026, 1, 2, 8, 0, 0, 0, loc)},
+		{DigestConfig{Schedule: DigestDaily, At: "18:15"}, time.Date(2026, 1, 1, 18, 15, 0, 0, loc)},
+		{DigestConfig{Schedule: DigestWeekly}, time.Date(2026, 1, 5, 8, 0, 0, 0, loc)},
+		{DigestConfig{Schedule: DigestWeekly, Weekday: "thu", At: "10:00"}, time.Date(2026, 1, 1, 10, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); err != nil {
+			t.Fatalf("%+v: %v", tt.config, err)
+		}
+		if got := tt.config.Next(now); !got.Equal(tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.config, got, tt.want)
+		}
+	}
+
+	for _, c := range []DigestConfig{{Schedule: "hourly"}, {Schedule: DigestDaily, At: "8am"}, {Schedule: DigestWeekly, Weekday: "someday"}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestDigestOnlyNotifier(t *testing.T) {
+	c := Config{Name: "mail", Digest: &DigestConfig{Schedule: DigestDaily}}
+	if c.accepts(testEvent()) {
+		t.Error("expected a digest notifier without events to skip events")
+	}
+	c.Events = []Kind{KindFiring}
+	if !c.accepts(testEvent()) {
+		t.Error("expected listed events to be sent")
+	}
+}
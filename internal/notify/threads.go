@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/user/subscriptions-monitor/internal/store"
+)
+
+// ThreadsFile is the name of the thread state file in the data directory
+const ThreadsFile = "threads.json"
+
+// ThreadStore keeps the messages of open threads of all notifiers, keyed by
+// notifier name and thread key. With a path every change is saved, so that
+// the event closing a thread after a restart still replies to or edits its
+// message.
+type ThreadStore struct {
+	mu   sync.Mutex
+	path string
+	refs map[string]json.RawMessage
+}
+
+// LoadThreads reads the thread state file at path. A missing file yields an
+// empty store.
+func LoadThreads(path string) (*ThreadStore, error) {
+	t := &ThreadStore{path: path, refs: make(map[string]json.RawMessage)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t.refs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func (t *ThreadStore) set(key string, ref json.RawMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.refs == nil {
+		t.refs = make(map[string]json.RawMessage)
+	}
+	t.refs[key] = ref
+	t.save()
+}
+
+// take returns and forgets the message of a thread.
+func (t *ThreadStore) take(key string) (json.RawMessage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ref, ok := t.refs[key]
+	if ok {
+		delete(t.refs, key)
+		t.save()
+	}
+	return ref, ok
+}
+
+// save atomically replaces the state file. Failing to save only costs the
+// threads after a restart, so it is a warning.
+func (t *ThreadStore) save() {
+	if t.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(t.refs, "", "  ")
+	if err == nil {
+		err = store.WriteFileAtomic(t.path, data, 0640)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to persist notifier threads: %v\n", err)
+	}
+}
+
+// threads remembers the message a notifier sent for an open thread, so that
+// the event closing it can reply to or edit that message. Until the
+// dispatcher hands it a ThreadStore it lives in memory only.
+type threads[T any] struct {
+	mu    sync.Mutex
+	store *ThreadStore
+	scope string
+}
+
+// threaded is implemented by notifiers that continue threads.
+type threaded interface {
+	useThreads(store *ThreadStore, scope string)
+}
+
+func (t *threads[T]) use(store *ThreadStore, scope string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.store, t.scope = store, scope
+}
+
+func (t *threads[T]) backing() *ThreadStore {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.store == nil {
+		t.store = &ThreadStore{}
+	}
+	return t.store
+}
+
+func (t *threads[T]) set(key string, ref T) {
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return
+	}
+	t.backing().set(t.scope+"/"+key, data)
+}
+
+// take returns and forgets the message of a thread.
+func (t *threads[T]) take(key string) (T, bool) {
+	var ref T
+	data, ok := t.backing().take(t.scope + "/" + key)
+	if !ok || json.Unmarshal(data, &ref) != nil {
+		return ref, false
+	}
+	return ref, true
+}
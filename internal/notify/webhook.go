@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// DefaultSignatureHeader carries the HMAC-SHA256 of the request body when a
+// webhook has a secret, as "sha256=<hex>".
+const DefaultSignatureHeader = "X-Sub-Mon-Signature"
+
+// webhook POSTs events to a URL. Without a template the body is the event
+// encoded as JSON, otherwise the output of the template executed with the
+// event.
+type webhook struct {
+	config   Config
+	template *template.Template
+	client   *http.Client
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value, e.g. to embed a string safely: {{json .Summary}}
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func newWebhook(c Config) (*webhook, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+
+	w := &webhook{
+		config: c,
+		client: &http.Client{Timeout: c.Timeout},
+	}
+	if c.Template != "" {
+		tmpl, err := template.New(c.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(c.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		w.template = tmpl
+	}
+	return w, nil
+}
+
+func (w *webhook) contentType() string {
+	for k, v := range w.config.Headers {
+		if strings.EqualFold(k, "Content-Type") {
+			return v
+		}
+	}
+	return "application/json"
+}
+
+// render returns the request body for ev. Templated bodies sent as JSON must
+// be valid JSON, so that template mistakes show up in the log rather than
+// as rejected requests.
+func (w *webhook) render(ev Event) ([]byte, error) {
+	if w.template == nil {
+		return json.Marshal(ev)
+	}
+
+	var buf bytes.Buffer
+	if err := w.template.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	if strings.HasPrefix(w.contentType(), "application/json") && !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("template did not produce valid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhook) Notify(ctx context.Context, ev Event) error {
+	body, err := w.render(ev)
+	if err != nil {
+		return err
+	}
+
+	_, err = send(ctx, w.client, w.config.Retry, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", w.contentType())
+		req.Header.Set("User-Agent", "sub-mon")
+		for k, v := range w.config.Headers {
+			req.Header.Set(k, v)
+		}
+		if w.config.Secret != "" {
+			header := w.config.SignatureHeader
+			if header == "" {
+				header = DefaultSignatureHeader
+			}
+			req.Header.Set(header, Sign(w.config.Secret, body))
+		}
+		return req, nil
+	})
+	return err
+}
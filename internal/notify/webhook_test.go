@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/alert"
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func testEvent() Event {
+	snap := provider.UsageSnapshot{
+		ProviderID: "kimi",
+		Name:       "sub-a",
+		Status:     provider.StatusOK,
+		Metrics: []provider.UsageMetric{{
+			Name:   "5h",
+			Amount: provider.UsageAmount{Used: provider.Ptr(95.0), Limit: provider.Ptr(100.0)},
+		}},
+	}
+	ev := alert.Event{State: alert.StateFiring, Alert: alert.Alert{
+		Rule: "high", Severity: alert.SeverityWarning, Subscription: "sub-a", Provider: "kimi",
+		Metric: "5h", When: "used_percent >= 90", Value: "95", State: alert.StateFiring,
+	}}
+	return AlertEvent(ev, snap, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+}
+
+func fastRetry(attempts int) provider.RetryPolicy {
+	return provider.RetryPolicy{MaxAttempts: attempts, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Auth   string
+	Header http.Header
+	Body   map[string]any
+}
+
+// recordingServer records requests and answers each with reply(request).
+func recordingServer(t *testing.T, reply func(recordedRequest) string) (*httptest.Server, func() []recordedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req := recordedRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Auth: r.Header.Get("Authorization"), Header: r.Header}
+		json.Unmarshal(body, &req.Body)
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+		io.WriteString(w, reply(req))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), reqs...)
+	}
+}
+
+func TestWebhook_DefaultBodyAndSignature(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{
+		Name:    "hook",
+		Type:    "webhook",
+		URL:     srv.URL,
+		Secret:  "s3cret",
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var got Event
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if got.Kind != KindFiring || got.Alert == nil || got.Alert.Rule != "high" || got.Snapshot.Name != "sub-a" {
+		t.Errorf("unexpected event: %+v", got)
+	}
+	if header.Get("Authorization") != "Bearer token" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if sig := header.Get(DefaultSignatureHeader); sig != Sign("s3cret", body) {
+		t.Errorf("unexpected signature %q", sig)
+	}
+}
+
+func TestWebhook_Template(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{
+		Name:     "hook",
+		Type:     "webhook",
+		URL:      srv.URL,
+		Template: `{"text": {{json .Summary}}, "used": {{with .Metric}}{{.Amount.Used}}{{end}}, "plan": {{json .Snapshot.Name}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("decode body %s: %v", body, err)
+	}
+	if got["used"] != 95.0 || got["plan"] != "sub-a" || got["text"] == "" {
+		t.Errorf("unexpected body: %s", body)
+	}
+}
+
+func TestWebhook_InvalidTemplateOutput(t *testing.T) {
+	n, err := New(Config{Name: "hook", Type: "webhook", URL: "http://127.0.0.1:1", Template: `{"text": {{.Summary}}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err == nil {
+		t.Error("expected an error for a template that does not produce JSON")
+	}
+}
+
+func TestWebhook_Retry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Name: "hook", Type: "webhook", URL: srv.URL, Retry: fastRetry(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("expected the third attempt to succeed: %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestWebhook_NoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Name: "hook", Type: "webhook", URL: srv.URL, Retry: fastRetry(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), testEvent())
+	if err == nil || strings.Contains(err.Error(), "giving up") {
+		t.Errorf("expected the client error without retry summary, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single attempt, got %d", n)
+	}
+}
+
+func TestWebhook_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Name: "hook", Type: "webhook", URL: srv.URL, Timeout: 20 * time.Millisecond, Retry: fastRetry(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err == nil {
+		t.Error("expected a timeout error")
+	}
+}
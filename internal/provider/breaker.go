@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// BreakerPolicy controls the per-subscription circuit breaker. After
+// FailureThreshold consecutive unauthorized responses the subscription is not
+// fetched again until Cooldown has passed, so that an account with expired
+// credentials is not hammered into getting flagged.
+type BreakerPolicy struct {
+	FailureThreshold int           `yaml:"failure_threshold" mapstructure:"failure_threshold" json:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown" mapstructure:"cooldown" json:"cooldown"`
+}
+
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		FailureThreshold: 3,
+		Cooldown:         30 * time.Minute,
+	}
+}
+
+type BreakerStateName string
+
+const (
+	BreakerClosed   BreakerStateName = "closed"
+	BreakerOpen     BreakerStateName = "open"
+	BreakerHalfOpen BreakerStateName = "half_open"
+)
+
+// BreakerState is the circuit breaker state reported in a UsageSnapshot
+type BreakerState struct {
+	State               BreakerStateName `json:"state"`
+	ConsecutiveFailures int              `json:"consecutive_failures"`
+	OpenedAt            *time.Time       `json:"opened_at,omitempty"`
+	RetryAt             *time.Time       `json:"retry_at,omitempty"`
+}
+
+type breaker struct {
+	mu       sync.Mutex
+	state    BreakerStateName
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker() *breaker {
+	return &breaker{state: BreakerClosed}
+}
+
+// allow reports whether a fetch may go ahead. Once the cool-down of an open
+// breaker has elapsed a single probe is let through.
+func (b *breaker) allow(policy BreakerPolicy, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < policy.Cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a fetch. Only unauthorized
+// responses count towards tripping; transient failures leave it untouched.
+func (b *breaker) record(policy BreakerPolicy, code ErrorCode, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	switch code {
+	case "":
+		b.state = BreakerClosed
+		b.failures = 0
+	case ErrorCodeUnauthorized:
+		b.failures++
+		if b.state == BreakerHalfOpen || (policy.FailureThreshold > 0 && b.failures >= policy.FailureThreshold) {
+			b.state = BreakerOpen
+			b.openedAt = now
+		}
+	default:
+		if b.state == BreakerHalfOpen {
+			b.state = BreakerOpen
+			b.openedAt = now
+		}
+	}
+}
+
+// release gives back a probe let through by allow when nothing was fetched.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) snapshot(policy BreakerPolicy) *BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &BreakerState{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != BreakerClosed {
+		s.OpenedAt = Ptr(b.openedAt)
+		s.RetryAt = Ptr(b.openedAt.Add(policy.Cooldown))
+	}
+	return s
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"sync"
+	"time"
+)
+
+// RequestCounter is implemented by providers whose usage fetch makes more
+// than one upstream request, so that every request is charged to the budget.
+type RequestCounter interface {
+	UsageRequests() int
+}
+
+// CostRequestCounter is the counterpart of RequestCounter for FetchCosts.
+type CostRequestCounter interface {
+	CostRequests() int
+}
+
+func usageRequests(p Provider) int {
+	if rc, ok := p.(RequestCounter); ok && rc.UsageRequests() > 0 {
+		return rc.UsageRequests()
+	}
+	return 1
+}
+
+func costRequests(p Provider) int {
+	if rc, ok := p.(CostRequestCounter); ok && rc.CostRequests() > 0 {
+		return rc.CostRequests()
+	}
+	return 1
+}
+
+// callBudget tracks the upstream requests of one subscription over a sliding
+// hour. A zero limit means unlimited.
+type callBudget struct {
+	mu    sync.Mutex
+	limit int
+	calls []time.Time
+}
+
+// take records n requests and reports true when the budget allows them.
+func (b *callBudget) take(now time.Time, n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return true
+	}
+
+	b.prune(now)
+	if len(b.calls)+n > b.limit {
+		return false
+	}
+	for range n {
+		b.calls = append(b.calls, now)
+	}
+	return true
+}
+
+// wait returns how long until the budget allows another n requests. A fetch
+// needing more requests than the whole budget waits a full hour.
+func (b *callBudget) wait(now time.Time, n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return 0
+	}
+
+	b.prune(now)
+	excess := len(b.calls) + n - b.limit
+	switch {
+	case excess <= 0:
+		return 0
+	case excess > len(b.calls):
+		return time.Hour
+	}
+	return b.calls[excess-1].Add(time.Hour).Sub(now)
+}
+
+func (b *callBudget) prune(now time.Time) {
+	cutoff := now.Add(-time.Hour)
+	i := 0
+	for i < len(b.calls) && !b.calls[i].After(cutoff) {
+		i++
+	}
+	b.calls = b.calls[i:]
+}
@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := &callBudget{limit: 3}
+
+	if !b.take(now, 2) || !b.take(now.Add(time.Minute), 1) {
+		t.Fatal("expected the first three requests to be allowed")
+	}
+	if b.take(now.Add(2*time.Minute), 1) {
+		t.Error("expected a fourth request within the hour to be refused")
+	}
+	if wait := b.wait(now.Add(2*time.Minute), 2); wait != 58*time.Minute {
+		t.Errorf("expected to wait 58m for the two oldest requests to expire, got %s", wait)
+	}
+	if wait := b.wait(now.Add(2*time.Minute), 3); wait != 59*time.Minute {
+		t.Errorf("expected to wait 59m for all requests to expire, got %s", wait)
+	}
+	if wait := b.wait(now, 4); wait != time.Hour {
+		t.Errorf("expected a fetch larger than the budget to wait an hour, got %s", wait)
+	}
+	if !b.take(now.Add(time.Hour+time.Second), 2) {
+		t.Error("expected requests to be allowed again after an hour")
+	}
+}
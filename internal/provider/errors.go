@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorCode is a machine-readable classification of a fetch failure
+type ErrorCode string
+
+const (
+	ErrorCodeUnauthorized  ErrorCode = "unauthorized"
+	ErrorCodeRateLimited   ErrorCode = "rate_limited"
+	ErrorCodeUnavailable   ErrorCode = "upstream_unavailable"
+	ErrorCodeSchemaChanged ErrorCode = "schema_changed"
+	ErrorCodeTimeout       ErrorCode = "timeout"
+	ErrorCodeBudgetSpent   ErrorCode = "budget_exhausted"
+	ErrorCodeUnknown       ErrorCode = "unknown"
+)
+
+// Sentinel errors adapters wrap (or match via HTTPError) so that callers can
+// classify failures with errors.Is.
+var (
+	ErrUnauthorized  = errors.New("credentials rejected")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrUnavailable   = errors.New("upstream unavailable")
+	ErrSchemaChanged = errors.New("unexpected response format")
+	ErrTimeout       = errors.New("request timed out")
+	ErrBudgetSpent   = errors.New("hourly call budget spent")
+)
+
+// HTTPError is returned by adapter clients when the upstream answers with a
+// non-success HTTP status. It matches the sentinel errors above.
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func NewHTTPError(resp *http.Response) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnavailable:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// DecodeJSON unmarshals an upstream response body, reporting decode failures
+// as ErrSchemaChanged.
+func DecodeJSON(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaChanged, err)
+	}
+	return nil
+}
+
+// ErrorCodeOf classifies err. When err joins several failures the most
+// actionable class wins, so an expired cookie is never hidden behind a
+// secondary timeout.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		return ErrorCodeUnauthorized
+	case errors.Is(err, ErrBudgetSpent):
+		return ErrorCodeBudgetSpent
+	case errors.Is(err, ErrRateLimited):
+		return ErrorCodeRateLimited
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeTimeout
+	case errors.Is(err, ErrUnavailable):
+		return ErrorCodeUnavailable
+	case errors.Is(err, ErrSchemaChanged):
+		return ErrorCodeSchemaChanged
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrorCodeTimeout
+		}
+		return ErrorCodeUnavailable
+	}
+
+	return ErrorCodeUnknown
+}
+
+// Status maps the error class to the snapshot status reported to users.
+func (c ErrorCode) Status() Status {
+	switch c {
+	case ErrorCodeUnauthorized:
+		return StatusUnauthorized
+	case ErrorCodeRateLimited, ErrorCodeBudgetSpent:
+		return StatusRateLimited
+	case ErrorCodeUnavailable, ErrorCodeTimeout:
+		return StatusUnavailable
+	case "":
+		return StatusOK
+	default:
+		return StatusError
+	}
+}
+
+// Hint returns a short, human readable suggestion for the error class.
+func (c ErrorCode) Hint() string {
+	switch c {
+	case ErrorCodeUnauthorized:
+		return "cookie expired, re-import credentials"
+	case ErrorCodeRateLimited:
+		return "rate limited by provider, try again later"
+	case ErrorCodeUnavailable:
+		return "provider temporarily unavailable"
+	case ErrorCodeSchemaChanged:
+		return "unexpected response from provider, sub-mon may need an update"
+	case ErrorCodeTimeout:
+		return "request timed out"
+	case ErrorCodeBudgetSpent:
+		return "hourly call budget spent, raise settings.max_calls_per_hour"
+	default:
+		return ""
+	}
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
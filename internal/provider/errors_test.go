@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"nil", nil, ""},
+		{"401", &HTTPError{StatusCode: http.StatusUnauthorized}, ErrorCodeUnauthorized},
+		{"403", &HTTPError{StatusCode: http.StatusForbidden}, ErrorCodeUnauthorized},
+		{"429", &HTTPError{StatusCode: http.StatusTooManyRequests}, ErrorCodeRateLimited},
+		{"502", &HTTPError{StatusCode: http.StatusBadGateway}, ErrorCodeUnavailable},
+		{"404", &HTTPError{StatusCode: http.StatusNotFound}, ErrorCodeUnknown},
+		{"wrapped unauthorized", fmt.Errorf("get_current: %w", ErrUnauthorized), ErrorCodeUnauthorized},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), ErrorCodeTimeout},
+		{"schema", DecodeJSON([]byte("<html>"), &struct{}{}), ErrorCodeSchemaChanged},
+		{"plain", errors.New("boom"), ErrorCodeUnknown},
+		{
+			"joined prefers unauthorized",
+			errors.Join(fmt.Errorf("a: %w", context.DeadlineExceeded), fmt.Errorf("b: %w", &HTTPError{StatusCode: 401})),
+			ErrorCodeUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCodeOf(tt.err); got != tt.want {
+				t.Errorf("ErrorCodeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCode_Status(t *testing.T) {
+	if ErrorCodeUnauthorized.Status() != StatusUnauthorized {
+		t.Error("expected unauthorized to map to StatusUnauthorized")
+	}
+	if ErrorCodeRateLimited.Status() != StatusRateLimited {
+		t.Error("expected rate_limited to map to StatusRateLimited")
+	}
+	if ErrorCodeTimeout.Status() != StatusUnavailable {
+		t.Error("expected timeout to map to StatusUnavailable")
+	}
+	if ErrorCodeSchemaChanged.Status() != StatusError {
+		t.Error("expected schema_changed to map to StatusError")
+	}
+}
@@ -0,0 +1,63 @@
+package provider
+
+// WithLastKnownGood returns cur unchanged when it succeeded or when there is
+// no previous success to fall back to. Otherwise it returns the plan, metrics
+// and cost of lastGood marked as stale, together with the status and error of
+// the failed refresh, so that a single failure does not wipe good numbers.
+// Whatever a partial failure did fetch replaces the last good values: its
+// plan, its cost and its metrics by name.
+func WithLastKnownGood(cur UsageSnapshot, lastGood *UsageSnapshot) UsageSnapshot {
+	if cur.Status == StatusOK || lastGood == nil {
+		return cur
+	}
+
+	out := *lastGood
+	out.Status = cur.Status
+	out.Error = cur.Error
+	out.ErrorCode = cur.ErrorCode
+	out.Breaker = cur.Breaker
+	out.FetchedAt = cur.FetchedAt
+	out.Stale = true
+
+	if cur.Plan != nil {
+		out.Plan = cur.Plan
+	}
+	if cur.Cost != nil {
+		out.Cost = cur.Cost
+	}
+	out.Metrics = mergeMetrics(lastGood.Metrics, cur.Metrics)
+
+	out.LastSuccessAt = lastGood.LastSuccessAt
+	if !lastGood.Stale {
+		out.LastSuccessAt = lastGood.FetchedAt
+		if out.LastSuccessAt == nil {
+			out.LastSuccessAt = Ptr(lastGood.Timestamp)
+		}
+	}
+
+	return out
+}
+
+// mergeMetrics returns old with the metrics of fresh replacing those of the
+// same name; fresh metrics without a counterpart are appended.
+func mergeMetrics(old, fresh []UsageMetric) []UsageMetric {
+	if len(fresh) == 0 {
+		return old
+	}
+
+	merged := make([]UsageMetric, len(old), len(old)+len(fresh))
+	copy(merged, old)
+	index := make(map[string]int, len(old))
+	for i, m := range merged {
+		index[m.Name] = i
+	}
+	for _, m := range fresh {
+		if i, ok := index[m.Name]; ok {
+			merged[i] = m
+			continue
+		}
+		index[m.Name] = len(merged)
+		merged = append(merged, m)
+	}
+	return merged
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithLastKnownGood(t *testing.T) {
+	goodAt := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	failedAt := goodAt.Add(12 * time.Minute)
+
+	good := UsageSnapshot{
+		Name:      "sub",
+		Timestamp: goodAt,
+		FetchedAt: Ptr(goodAt),
+		Plan:      &PlanInfo{Name: "Ultra"},
+		Metrics: []UsageMetric{
+			{Name: "5h Flows", Amount: UsageAmount{Used: Ptr(10.0), Limit: Ptr(100.0)}},
+		},
+		Status: StatusOK,
+	}
+	failed := UsageSnapshot{
+		Name:      "sub",
+		Timestamp: failedAt,
+		FetchedAt: Ptr(failedAt),
+		Metrics:   []UsageMetric{},
+		Status:    StatusUnavailable,
+		Error:     "unexpected status code: 502",
+		ErrorCode: ErrorCodeUnavailable,
+	}
+
+	if got := WithLastKnownGood(failed, nil); got.Stale {
+		t.Error("expected no fallback without a previous success")
+	}
+	if got := WithLastKnownGood(good, &good); got.Stale {
+		t.Error("expected successful snapshot to be returned as-is")
+	}
+
+	got := WithLastKnownGood(failed, &good)
+	if !got.Stale {
+		t.Fatal("expected fallback snapshot to be marked stale")
+	}
+	if len(got.Metrics) != 1 || got.Plan == nil || got.Plan.Name != "Ultra" {
+		t.Errorf("expected last good plan and metrics, got %+v", got)
+	}
+	if got.Status != StatusUnavailable || got.ErrorCode != ErrorCodeUnavailable || got.Error == "" {
+		t.Errorf("expected current error to be kept, got %s/%s/%q", got.Status, got.ErrorCode, got.Error)
+	}
+	if got.LastSuccessAt == nil || !got.LastSuccessAt.Equal(goodAt) {
+		t.Errorf("expected last success at %v, got %v", goodAt, got.LastSuccessAt)
+	}
+	if !got.FetchedAt.Equal(failedAt) {
+		t.Errorf("expected fetched_at of the failed refresh, got %v", got.FetchedAt)
+	}
+}
+
+func TestWithLastKnownGood_MergesPartialMetrics(t *testing.T) {
+	good := UsageSnapshot{
+		Name:   "sub",
+		Status: StatusOK,
+		Metrics: []UsageMetric{
+			{Name: "5h Flows", Amount: UsageAmount{Used: Ptr(10.0)}},
+			{Name: "Total Tokens", Amount: UsageAmount{Used: Ptr(500.0)}},
+		},
+	}
+	partial := UsageSnapshot{
+		Name:    "sub",
+		Status:  StatusError,
+		Error:   "partial data",
+		Metrics: []UsageMetric{{Name: "5h Flows", Amount: UsageAmount{Used: Ptr(20.0)}}},
+	}
+
+	got := WithLastKnownGood(partial, &good)
+	if !got.Stale || len(got.Metrics) != 2 {
+		t.Fatalf("expected the merged metrics marked stale, got %+v", got)
+	}
+	if used := *got.Metrics[0].Amount.Used; used != 20 {
+		t.Errorf("expected the fresh 5h Flows value, got %v", used)
+	}
+	if used := *got.Metrics[1].Amount.Used; used != 500 {
+		t.Errorf("expected the last good Total Tokens value, got %v", used)
+	}
+	if *good.Metrics[0].Amount.Used != 10 {
+		t.Error("expected the last good snapshot to be left unchanged")
+	}
+}
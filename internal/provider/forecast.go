@@ -0,0 +1,67 @@
+package provider
+
+import "time"
+
+// minForecastSpan is the shortest observation span a burn rate is computed
+// from, shorter spans make a single request look like a runaway rate.
+const minForecastSpan = time.Minute
+
+// Forecast predicts when a metric runs out at its recent burn rate.
+// BurnRate is in units of the metric per hour. ExhaustsAt is only set while
+// usage is growing; ExhaustsBeforeReset tells whether that happens before
+// the window resets.
+type Forecast struct {
+	BurnRate            float64    `json:"burn_rate"`
+	ExhaustsAt          *time.Time `json:"exhausts_at,omitempty"`
+	ExhaustsBeforeReset bool       `json:"exhausts_before_reset"`
+}
+
+// UsagePoint is an observed used value of a metric.
+type UsagePoint struct {
+	Time time.Time
+	Used float64
+}
+
+// ForecastMetric computes the forecast of m from earlier observations of the
+// same metric, ordered by time. Observations from before the latest drop in
+// usage belong to a previous window and are ignored. It returns nil when m has
+// no used value or limit, or when the observations span too little time.
+func ForecastMetric(m UsageMetric, points []UsagePoint, now time.Time) *Forecast {
+	if m.Amount.Used == nil || m.Amount.Limit == nil || *m.Amount.Limit <= 0 {
+		return nil
+	}
+	used, limit := *m.Amount.Used, *m.Amount.Limit
+
+	var first *UsagePoint
+	for i := range points {
+		p := &points[i]
+		if !p.Time.Before(now) {
+			break
+		}
+		if first == nil || p.Used < points[i-1].Used {
+			first = p
+		}
+	}
+	if first == nil || first.Used > used {
+		return nil
+	}
+
+	span := now.Sub(first.Time)
+	if span < minForecastSpan {
+		return nil
+	}
+
+	f := &Forecast{BurnRate: (used - first.Used) / span.Hours()}
+	switch {
+	case used >= limit:
+		f.ExhaustsAt = Ptr(now)
+	case f.BurnRate > 0:
+		remaining := time.Duration((limit - used) / f.BurnRate * float64(time.Hour))
+		f.ExhaustsAt = Ptr(now.Add(remaining))
+	default:
+		return f
+	}
+
+	f.ExhaustsBeforeReset = m.Window.ResetsAt == nil || f.ExhaustsAt.Before(*m.Window.ResetsAt)
+	return f
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func forecastMetric(used, limit float64, resetsAt *time.Time) UsageMetric {
+	return UsageMetric{
+		Name:   "5h Flows",
+		Window: UsageWindow{ID: "5h", ResetsAt: resetsAt},
+		Amount: UsageAmount{Used: Ptr(used), Limit: Ptr(limit)},
+	}
+}
+
+func TestForecastMetric_ExhaustsBeforeReset(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	resetsAt := now.Add(3 * time.Hour)
+	points := []UsagePoint{
+		{Time: now.Add(-time.Hour), Used: 100},
+		{Time: now.Add(-30 * time.Minute), Used: 130},
+	}
+
+	f := ForecastMetric(forecastMetric(160, 260, &resetsAt), points, now)
+	if f == nil {
+		t.Fatal("expected a forecast")
+	}
+	if f.BurnRate != 60 {
+		t.Errorf("expected burn rate 60/h, got %v", f.BurnRate)
+	}
+	if f.ExhaustsAt == nil || !f.ExhaustsAt.Equal(now.Add(100*time.Minute)) {
+		t.Errorf("expected exhaustion in 1h40m, got %v", f.ExhaustsAt)
+	}
+	if !f.ExhaustsBeforeReset {
+		t.Error("expected exhaustion before reset")
+	}
+}
+
+func TestForecastMetric_LastsUntilReset(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	resetsAt := now.Add(time.Hour)
+	points := []UsagePoint{{Time: now.Add(-time.Hour), Used: 100}}
+
+	f := ForecastMetric(forecastMetric(110, 1000, &resetsAt), points, now)
+	if f == nil || f.ExhaustsAt == nil || f.ExhaustsBeforeReset {
+		t.Errorf("expected exhaustion after the reset, got %+v", f)
+	}
+}
+
+func TestForecastMetric_IgnoresPreviousWindow(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	points := []UsagePoint{
+		{Time: now.Add(-50 * time.Minute), Used: 900},
+		{Time: now.Add(-40 * time.Minute), Used: 950},
+		{Time: now.Add(-30 * time.Minute), Used: 0},
+	}
+
+	f := ForecastMetric(forecastMetric(30, 1000, nil), points, now)
+	if f == nil || f.BurnRate != 60 {
+		t.Errorf("expected burn rate from the current window only, got %+v", f)
+	}
+}
+
+func TestForecastMetric_NoForecast(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	if f := ForecastMetric(forecastMetric(10, 100, nil), nil, now); f != nil {
+		t.Errorf("expected no forecast without history, got %+v", f)
+	}
+	recent := []UsagePoint{{Time: now.Add(-10 * time.Second), Used: 5}}
+	if f := ForecastMetric(forecastMetric(10, 100, nil), recent, now); f != nil {
+		t.Errorf("expected no forecast for a short span, got %+v", f)
+	}
+	idle := []UsagePoint{{Time: now.Add(-time.Hour), Used: 10}}
+	if f := ForecastMetric(forecastMetric(10, 100, nil), idle, now); f == nil || f.BurnRate != 0 || f.ExhaustsAt != nil {
+		t.Errorf("expected zero burn rate without exhaustion, got %+v", f)
+	}
+}
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultPeriodLength = 30 * 24 * time.Hour
+
+// ParsePeriod builds a TimePeriod from user supplied bounds. Both bounds accept
+// either a date (2006-01-02) or an RFC3339 timestamp. A date-only end bound is
+// inclusive and covers the whole day. An empty end defaults to now and an empty
+// start defaults to 30 days before the end.
+func ParsePeriod(from, to string, now time.Time) (TimePeriod, error) {
+	var period TimePeriod
+
+	end := now
+	if strings.TrimSpace(to) != "" {
+		t, dateOnly, err := parsePeriodBound(to, now.Location())
+		if err != nil {
+			return period, fmt.Errorf("invalid end %q: %w", to, err)
+		}
+		end = t
+		if dateOnly {
+			end = t.AddDate(0, 0, 1)
+		}
+	}
+
+	start := end.Add(-defaultPeriodLength)
+	if strings.TrimSpace(from) != "" {
+		t, _, err := parsePeriodBound(from, now.Location())
+		if err != nil {
+			return period, fmt.Errorf("invalid start %q: %w", from, err)
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return period, fmt.Errorf("start %s must be before end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	period.Start = start
+	period.End = end
+	return period, nil
+}
+
+func parsePeriodBound(s string, loc *time.Location) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected YYYY-MM-DD or RFC3339")
+	}
+	return t, false, nil
+}
@@ -10,6 +10,9 @@ type Provider interface {
 	FetchUsage(ctx context.Context, auth AuthConfig) (*UsageSnapshot, error)
 }
 
+// CostProvider is implemented by providers that report costs. FetchCosts may
+// return a breakdown together with an error when only part of it could be
+// fetched.
 type CostProvider interface {
 	Provider
 	FetchCosts(ctx context.Context, auth AuthConfig, period TimePeriod) (*CostBreakdown, error)
@@ -2,24 +2,75 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Registry struct {
-	mu        sync.RWMutex
-	providers map[string]Provider
+	mu            sync.RWMutex
+	providers     map[string]Provider
+	retry         RetryPolicy
+	breakerPolicy BreakerPolicy
+	breakers      map[string]*breaker
+	budgetLimit   int
+	budgets       map[string]*callBudget
+	now           func() time.Time
+	warnMu        sync.Mutex
+	warnOut       io.Writer
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		providers: make(map[string]Provider),
+		providers:     make(map[string]Provider),
+		retry:         DefaultRetryPolicy(),
+		breakerPolicy: DefaultBreakerPolicy(),
+		breakers:      make(map[string]*breaker),
+		budgets:       make(map[string]*callBudget),
+		now:           time.Now,
 	}
 }
 
+func (r *Registry) SetRetryPolicy(p RetryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retry = p
+}
+
+func (r *Registry) SetBreakerPolicy(p BreakerPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakerPolicy = p
+}
+
+// SetCallBudget caps the upstream requests made per subscription and hour,
+// retries included. 0 means unlimited.
+func (r *Registry) SetCallBudget(perHour int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.budgetLimit = perHour
+	for _, b := range r.budgets {
+		b.mu.Lock()
+		b.limit = perHour
+		b.mu.Unlock()
+	}
+}
+
+// BudgetWait returns how long until the call budget of e allows another
+// fetch, 0 when it can be fetched right away.
+func (r *Registry) BudgetWait(e SubscriptionEntry) time.Duration {
+	p, ok := r.Get(e.Provider)
+	if !ok {
+		return 0
+	}
+	return r.budgetFor(e).wait(r.now(), usageRequests(p))
+}
+
 func (r *Registry) Register(p Provider) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -60,65 +111,200 @@ func (r *Registry) All() []Provider {
 	return ps
 }
 
+// Result is a single snapshot delivered by FetchStream. Index is the
+// position of the subscription in the entries passed to FetchStream.
+type Result struct {
+	Index    int
+	Snapshot UsageSnapshot
+}
+
+// FetchStream fetches all entries concurrently and delivers each snapshot as
+// soon as its provider returns. The channel is closed once every entry has
+// been delivered; it always yields exactly len(entries) results.
+func (r *Registry) FetchStream(ctx context.Context, entries []SubscriptionEntry) <-chan Result {
+	out := make(chan Result, len(entries))
+	var wg sync.WaitGroup
+
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(idx int, e SubscriptionEntry) {
+			defer wg.Done()
+			out <- Result{Index: idx, Snapshot: r.fetchOne(ctx, e)}
+		}(i, entry)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// FetchAll fetches all entries and returns their snapshots in entry order.
 func (r *Registry) FetchAll(ctx context.Context, entries []SubscriptionEntry) []UsageSnapshot {
 	results := make([]UsageSnapshot, len(entries))
+	for res := range r.FetchStream(ctx, entries) {
+		results[res.Index] = res.Snapshot
+	}
+	return results
+}
+
+func (r *Registry) fetchOne(ctx context.Context, e SubscriptionEntry) UsageSnapshot {
+	if ctx.Err() != nil {
+		errMsg := ctx.Err().Error()
+		code := ErrorCodeOf(ctx.Err())
+		r.logFetchWarning(e.Provider, e.Name, errMsg)
+		return UsageSnapshot{
+			ProviderID: e.Provider,
+			Name:       e.Name,
+			Timestamp:  r.now(),
+			Metrics:    []UsageMetric{},
+			Status:     code.Status(),
+			Error:      errMsg,
+			ErrorCode:  code,
+		}
+	}
+
+	p, ok := r.Get(e.Provider)
+	if !ok {
+		errMsg := fmt.Sprintf("provider %q not registered", e.Provider)
+		r.logFetchWarning(e.Provider, e.Name, errMsg)
+		return UsageSnapshot{
+			ProviderID: e.Provider,
+			Name:       e.Name,
+			Timestamp:  r.now(),
+			Metrics:    []UsageMetric{},
+			Status:     StatusError,
+			Error:      errMsg,
+		}
+	}
+
+	r.mu.RLock()
+	retryPolicy, breakerPolicy := r.retry, r.breakerPolicy
+	r.mu.RUnlock()
+
+	budget, requests := r.budgetFor(e), usageRequests(p)
+	if wait := budget.wait(r.now(), requests); wait > 0 {
+		return r.budgetSpent(e, p, wait)
+	}
+
+	br := r.breakerFor(e)
+	if !br.allow(breakerPolicy, r.now()) {
+		state := br.snapshot(breakerPolicy)
+		errMsg := fmt.Sprintf("provider %q circuit open after %d consecutive unauthorized responses, next attempt at %s",
+			e.Provider, state.ConsecutiveFailures, state.RetryAt.Format(time.RFC3339))
+		return UsageSnapshot{
+			ProviderID:  e.Provider,
+			DisplayName: p.DisplayName(),
+			Name:        e.Name,
+			Timestamp:   r.now(),
+			Metrics:     []UsageMetric{},
+			Status:      StatusUnauthorized,
+			Error:       errMsg,
+			ErrorCode:   ErrorCodeUnauthorized,
+			Breaker:     state,
+		}
+	}
+
+	charge := func() bool { return budget.take(r.now(), requests) }
+	snap, err := withRetry(ctx, retryPolicy, charge, func() (*UsageSnapshot, error) {
+		return p.FetchUsage(ctx, e.Auth)
+	})
+	if errors.Is(err, ErrBudgetSpent) {
+		br.release()
+		return r.budgetSpent(e, p, budget.wait(r.now(), requests))
+	}
+	if err != nil {
+		code := ErrorCodeOf(err)
+		br.record(breakerPolicy, code, r.now())
+
+		errMsg := fmt.Sprintf("provider %q fetch failed: %v", e.Provider, err)
+		r.logFetchWarning(e.Provider, e.Name, errMsg)
+		return UsageSnapshot{
+			ProviderID:  e.Provider,
+			DisplayName: p.DisplayName(),
+			Name:        e.Name,
+			Timestamp:   r.now(),
+			Metrics:     []UsageMetric{},
+			Status:      code.Status(),
+			Error:       errMsg,
+			ErrorCode:   code,
+			Breaker:     br.snapshot(breakerPolicy),
+		}
+	}
+
+	br.record(breakerPolicy, snap.ErrorCode, r.now())
+
+	snap.Name = e.Name
+	if snap.Status != StatusOK && snap.Error != "" {
+		snap.Error = fmt.Sprintf("provider %q fetch failed: %s", e.Provider, snap.Error)
+		r.logFetchWarning(e.Provider, e.Name, snap.Error)
+	}
+	if snap.Status != StatusOK && snap.Metrics == nil {
+		snap.Metrics = []UsageMetric{}
+	}
+	snap.Breaker = br.snapshot(breakerPolicy)
+	ApplyPace(snap)
+	return *snap
+}
+
+// budgetSpent is the snapshot of a subscription that was not fetched because
+// its call budget is spent.
+func (r *Registry) budgetSpent(e SubscriptionEntry, p Provider, wait time.Duration) UsageSnapshot {
+	return UsageSnapshot{
+		ProviderID:  e.Provider,
+		DisplayName: p.DisplayName(),
+		Name:        e.Name,
+		Timestamp:   r.now(),
+		Metrics:     []UsageMetric{},
+		Status:      ErrorCodeBudgetSpent.Status(),
+		Error: fmt.Sprintf("provider %q hourly call budget spent, next attempt at %s",
+			e.Provider, r.now().Add(wait).Format(time.RFC3339)),
+		ErrorCode: ErrorCodeBudgetSpent,
+	}
+}
+
+func (r *Registry) budgetFor(e SubscriptionEntry) *callBudget {
+	key := e.Provider + "/" + e.Name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.budgets[key]
+	if !ok {
+		b = &callBudget{limit: r.budgetLimit}
+		r.budgets[key] = b
+	}
+	return b
+}
+
+func (r *Registry) breakerFor(e SubscriptionEntry) *breaker {
+	key := e.Provider + "/" + e.Name
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.breakers[key]
+	if !ok {
+		b = newBreaker()
+		r.breakers[key] = b
+	}
+	return b
+}
+
+// FetchCosts queries the cost breakdown of every entry whose provider
+// implements CostProvider. Entries backed by other providers are reported
+// with StatusUnsupported so callers can tell them apart from failures.
+func (r *Registry) FetchCosts(ctx context.Context, entries []SubscriptionEntry, period TimePeriod) []CostResult {
+	results := make([]CostResult, len(entries))
 	var wg sync.WaitGroup
 
 	for i, entry := range entries {
 		wg.Add(1)
 		go func(idx int, e SubscriptionEntry) {
 			defer wg.Done()
-
-			if ctx.Err() != nil {
-				errMsg := ctx.Err().Error()
-				results[idx] = UsageSnapshot{
-					ProviderID: e.Provider,
-					Name:       e.Name,
-					Metrics:    []UsageMetric{},
-					Status:     StatusError,
-					Error:      errMsg,
-				}
-				logFetchWarning(e.Provider, e.Name, errMsg)
-				return
-			}
-
-			p, ok := r.Get(e.Provider)
-			if !ok {
-				errMsg := fmt.Sprintf("provider %q not registered", e.Provider)
-				results[idx] = UsageSnapshot{
-					ProviderID: e.Provider,
-					Name:       e.Name,
-					Metrics:    []UsageMetric{},
-					Status:     StatusError,
-					Error:      errMsg,
-				}
-				logFetchWarning(e.Provider, e.Name, errMsg)
-				return
-			}
-
-			snap, err := p.FetchUsage(ctx, e.Auth)
-			if err != nil {
-				errMsg := fmt.Sprintf("provider %q fetch failed: %v", e.Provider, err)
-				results[idx] = UsageSnapshot{
-					ProviderID:  e.Provider,
-					DisplayName: p.DisplayName(),
-					Name:        e.Name,
-					Metrics:     []UsageMetric{},
-					Status:      StatusError,
-					Error:       errMsg,
-				}
-				logFetchWarning(e.Provider, e.Name, errMsg)
-				return
-			}
-			snap.Name = e.Name
-			if snap.Status == StatusError && snap.Error != "" {
-				snap.Error = fmt.Sprintf("provider %q fetch failed: %s", e.Provider, snap.Error)
-				logFetchWarning(e.Provider, e.Name, snap.Error)
-			}
-			if snap.Status == StatusError && snap.Metrics == nil {
-				snap.Metrics = []UsageMetric{}
-			}
-			results[idx] = *snap
+			results[idx] = r.fetchCost(ctx, e, period)
 		}(i, entry)
 	}
 
@@ -126,16 +312,119 @@ func (r *Registry) FetchAll(ctx context.Context, entries []SubscriptionEntry) []
 	return results
 }
 
-func logFetchWarning(providerID, name, errMsg string) {
+// fetchCost queries a single cost breakdown under the same retry policy,
+// circuit breaker and call budget as usage fetches.
+func (r *Registry) fetchCost(ctx context.Context, e SubscriptionEntry, period TimePeriod) CostResult {
+	result := CostResult{
+		ProviderID: e.Provider,
+		Name:       e.Name,
+	}
+
+	p, ok := r.Get(e.Provider)
+	if !ok {
+		result.Status = StatusError
+		result.Error = fmt.Sprintf("provider %q not registered", e.Provider)
+		r.logFetchWarning(e.Provider, e.Name, result.Error)
+		return result
+	}
+	result.DisplayName = p.DisplayName()
+
+	cp, ok := p.(CostProvider)
+	if !ok {
+		result.Status = StatusUnsupported
+		result.Error = fmt.Sprintf("provider %q does not support cost breakdown", e.Provider)
+		return result
+	}
+
+	if ctx.Err() != nil {
+		result.ErrorCode = ErrorCodeOf(ctx.Err())
+		result.Status = result.ErrorCode.Status()
+		result.Error = ctx.Err().Error()
+		r.logFetchWarning(e.Provider, e.Name, result.Error)
+		return result
+	}
+
+	r.mu.RLock()
+	retryPolicy, breakerPolicy := r.retry, r.breakerPolicy
+	r.mu.RUnlock()
+
+	budget, requests := r.budgetFor(e), costRequests(p)
+	if wait := budget.wait(r.now(), requests); wait > 0 {
+		return r.costBudgetSpent(result, wait)
+	}
+
+	br := r.breakerFor(e)
+	if !br.allow(breakerPolicy, r.now()) {
+		state := br.snapshot(breakerPolicy)
+		result.ErrorCode = ErrorCodeUnauthorized
+		result.Status = StatusUnauthorized
+		result.Error = fmt.Sprintf("provider %q circuit open after %d consecutive unauthorized responses, next attempt at %s",
+			e.Provider, state.ConsecutiveFailures, state.RetryAt.Format(time.RFC3339))
+		return result
+	}
+
+	charge := func() bool { return budget.take(r.now(), requests) }
+	cost, err := withRetry(ctx, retryPolicy, charge, func() (*CostBreakdown, error) {
+		return cp.FetchCosts(ctx, e.Auth, period)
+	})
+	if errors.Is(err, ErrBudgetSpent) {
+		br.release()
+		return r.costBudgetSpent(result, budget.wait(r.now(), requests))
+	}
+	br.record(breakerPolicy, ErrorCodeOf(err), r.now())
+
+	if err != nil {
+		result.ErrorCode = ErrorCodeOf(err)
+		result.Status = result.ErrorCode.Status()
+		result.Error = fmt.Sprintf("provider %q cost fetch failed: %v", e.Provider, err)
+		r.logFetchWarning(e.Provider, e.Name, result.Error)
+		if cost != nil {
+			// Partial data, keep what was fetched.
+			result.Status = StatusError
+			result.Cost = cost
+		}
+		return result
+	}
+
+	result.Status = StatusOK
+	result.Cost = cost
+	return result
+}
+
+func (r *Registry) costBudgetSpent(result CostResult, wait time.Duration) CostResult {
+	result.ErrorCode = ErrorCodeBudgetSpent
+	result.Status = ErrorCodeBudgetSpent.Status()
+	result.Error = fmt.Sprintf("provider %q hourly call budget spent, next attempt at %s",
+		result.ProviderID, r.now().Add(wait).Format(time.RFC3339))
+	return result
+}
+
+// SetWarningOutput redirects fetch warnings, which go to os.Stderr by
+// default. Passing nil restores the default.
+func (r *Registry) SetWarningOutput(w io.Writer) {
+	r.warnMu.Lock()
+	defer r.warnMu.Unlock()
+	r.warnOut = w
+}
+
+func (r *Registry) logFetchWarning(providerID, name, errMsg string) {
 	errMsg = strings.TrimSpace(errMsg)
 	if errMsg == "" {
 		return
 	}
 
+	r.warnMu.Lock()
+	defer r.warnMu.Unlock()
+
+	var w io.Writer = os.Stderr
+	if r.warnOut != nil {
+		w = r.warnOut
+	}
+
 	if name != "" {
-		fmt.Fprintf(os.Stderr, "Warning: provider %q (%s) fetch failed: %s\n", providerID, name, errMsg)
+		fmt.Fprintf(w, "Warning: provider %q (%s) fetch failed: %s\n", providerID, name, errMsg)
 		return
 	}
 
-	fmt.Fprintf(os.Stderr, "Warning: provider %q fetch failed: %s\n", providerID, errMsg)
+	fmt.Fprintf(w, "Warning: provider %q fetch failed: %s\n", providerID, errMsg)
 }
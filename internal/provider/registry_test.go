@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -45,6 +47,7 @@ type mockProvider struct {
 	displayName string
 	failFetch   bool
 	statusError bool
+	fetchErr    error
 }
 
 func (m *mockProvider) ID() string {
@@ -67,6 +70,9 @@ func (m *mockProvider) ValidateAuth(ctx context.Context, auth AuthConfig) error
 }
 
 func (m *mockProvider) FetchUsage(ctx context.Context, auth AuthConfig) (*UsageSnapshot, error) {
+	if m.fetchErr != nil {
+		return nil, m.fetchErr
+	}
 	if m.failFetch {
 		return nil, errors.New("failed to fetch usage")
 	}
@@ -222,6 +228,9 @@ func TestRegistry_FetchAll(t *testing.T) {
 	if len(snapshots[2].Metrics) != 0 {
 		t.Errorf("expected empty metrics for not-registered provider, got %d", len(snapshots[2].Metrics))
 	}
+	if snapshots[2].Timestamp.IsZero() {
+		t.Error("expected a timestamp for not-registered provider")
+	}
 }
 
 func TestRegistry_FetchAll_StatusErrorSnapshotUsesEmptyMetrics(t *testing.T) {
@@ -256,3 +265,424 @@ func TestRegistry_FetchAll_StatusErrorSnapshotUsesEmptyMetrics(t *testing.T) {
 		t.Errorf("expected warning for status-provider, got %q", warnings)
 	}
 }
+
+func TestRegistry_FetchAll_ClassifiesErrors(t *testing.T) {
+	r := NewRegistry()
+	r.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	r.Register(&mockProvider{id: "expired", fetchErr: fmt.Errorf("get: %w", &HTTPError{StatusCode: 401})})
+	r.Register(&mockProvider{id: "throttled", fetchErr: &HTTPError{StatusCode: 429}})
+	r.Register(&mockProvider{id: "down", fetchErr: &HTTPError{StatusCode: 502}})
+
+	entries := []SubscriptionEntry{
+		{Provider: "expired", Name: "a"},
+		{Provider: "throttled", Name: "b"},
+		{Provider: "down", Name: "c"},
+	}
+
+	var snapshots []UsageSnapshot
+	captureStderr(t, func() {
+		snapshots = r.FetchAll(context.Background(), entries)
+	})
+
+	expected := []struct {
+		status Status
+		code   ErrorCode
+	}{
+		{StatusUnauthorized, ErrorCodeUnauthorized},
+		{StatusRateLimited, ErrorCodeRateLimited},
+		{StatusUnavailable, ErrorCodeUnavailable},
+	}
+	for i, want := range expected {
+		if snapshots[i].Status != want.status {
+			t.Errorf("%s: expected status %s, got %s", entries[i].Provider, want.status, snapshots[i].Status)
+		}
+		if snapshots[i].ErrorCode != want.code {
+			t.Errorf("%s: expected error code %s, got %s", entries[i].Provider, want.code, snapshots[i].ErrorCode)
+		}
+	}
+}
+
+type flakyProvider struct {
+	mockProvider
+	mu    sync.Mutex
+	calls int
+	errs  []error
+}
+
+func (f *flakyProvider) FetchUsage(ctx context.Context, auth AuthConfig) (*UsageSnapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return &UsageSnapshot{ProviderID: f.id, Status: StatusOK}, nil
+}
+
+func TestRegistry_FetchAll_RetriesTransientErrors(t *testing.T) {
+	r := NewRegistry()
+	r.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+	transient := &flakyProvider{
+		mockProvider: mockProvider{id: "transient"},
+		errs:         []error{&HTTPError{StatusCode: 502}, &HTTPError{StatusCode: 429}},
+	}
+	permanent := &flakyProvider{
+		mockProvider: mockProvider{id: "permanent"},
+		errs:         []error{&HTTPError{StatusCode: 401}, &HTTPError{StatusCode: 401}},
+	}
+	r.Register(transient)
+	r.Register(permanent)
+
+	var snapshots []UsageSnapshot
+	captureStderr(t, func() {
+		snapshots = r.FetchAll(context.Background(), []SubscriptionEntry{
+			{Provider: "transient", Name: "a"},
+			{Provider: "permanent", Name: "b"},
+		})
+	})
+
+	if snapshots[0].Status != StatusOK {
+		t.Errorf("expected transient provider to recover, got %s (%s)", snapshots[0].Status, snapshots[0].Error)
+	}
+	if transient.calls != 3 {
+		t.Errorf("expected 3 attempts for transient provider, got %d", transient.calls)
+	}
+	if snapshots[1].Status != StatusUnauthorized {
+		t.Errorf("expected unauthorized for permanent provider, got %s", snapshots[1].Status)
+	}
+	if permanent.calls != 1 {
+		t.Errorf("expected unauthorized failures not to be retried, got %d attempts", permanent.calls)
+	}
+}
+
+func TestRegistry_FetchAll_CircuitBreaker(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	r.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	r.SetBreakerPolicy(BreakerPolicy{FailureThreshold: 2, Cooldown: 10 * time.Minute})
+
+	p := &flakyProvider{
+		mockProvider: mockProvider{id: "expired"},
+		errs: []error{
+			&HTTPError{StatusCode: 401},
+			&HTTPError{StatusCode: 401},
+			&HTTPError{StatusCode: 401},
+		},
+	}
+	r.Register(p)
+	entries := []SubscriptionEntry{{Provider: "expired", Name: "acct"}}
+
+	fetch := func() UsageSnapshot {
+		var snaps []UsageSnapshot
+		captureStderr(t, func() {
+			snaps = r.FetchAll(context.Background(), entries)
+		})
+		return snaps[0]
+	}
+
+	fetch()
+	snap := fetch()
+	if snap.Breaker == nil || snap.Breaker.State != BreakerOpen {
+		t.Fatalf("expected breaker to open after 2 unauthorized responses, got %+v", snap.Breaker)
+	}
+
+	snap = fetch()
+	if p.calls != 2 {
+		t.Errorf("expected open breaker to skip the upstream call, got %d calls", p.calls)
+	}
+	if snap.Status != StatusUnauthorized || snap.ErrorCode != ErrorCodeUnauthorized {
+		t.Errorf("expected unauthorized snapshot while open, got %s/%s", snap.Status, snap.ErrorCode)
+	}
+
+	now = now.Add(11 * time.Minute)
+	snap = fetch()
+	if p.calls != 3 {
+		t.Errorf("expected a probe after cool-down, got %d calls", p.calls)
+	}
+	if snap.Breaker.State != BreakerOpen {
+		t.Errorf("expected failed probe to reopen breaker, got %s", snap.Breaker.State)
+	}
+
+	now = now.Add(11 * time.Minute)
+	snap = fetch()
+	if snap.Status != StatusOK {
+		t.Fatalf("expected successful probe, got %s", snap.Status)
+	}
+	if snap.Breaker.State != BreakerClosed || snap.Breaker.ConsecutiveFailures != 0 {
+		t.Errorf("expected breaker to close after success, got %+v", snap.Breaker)
+	}
+}
+
+type multiRequestProvider struct {
+	flakyProvider
+}
+
+func (m *multiRequestProvider) UsageRequests() int {
+	return 2
+}
+
+func TestRegistry_FetchAll_CallBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	r.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	r.SetCallBudget(4)
+
+	p := &multiRequestProvider{flakyProvider{
+		mockProvider: mockProvider{id: "multi"},
+		errs:         []error{&HTTPError{StatusCode: 502}, &HTTPError{StatusCode: 502}, &HTTPError{StatusCode: 502}},
+	}}
+	r.Register(p)
+	entries := []SubscriptionEntry{{Provider: "multi", Name: "acct"}}
+
+	fetch := func() UsageSnapshot {
+		var snaps []UsageSnapshot
+		captureStderr(t, func() {
+			snaps = r.FetchAll(context.Background(), entries)
+		})
+		return snaps[0]
+	}
+
+	snap := fetch()
+	if p.calls != 2 {
+		t.Errorf("expected retries to stop once 4 requests are spent, got %d attempts", p.calls)
+	}
+	if snap.ErrorCode != ErrorCodeUnavailable {
+		t.Errorf("expected the upstream failure to be reported, got %s (%s)", snap.ErrorCode, snap.Error)
+	}
+
+	if wait := r.BudgetWait(entries[0]); wait != time.Hour {
+		t.Errorf("expected to wait an hour for the budget, got %s", wait)
+	}
+	snap = fetch()
+	if p.calls != 2 {
+		t.Errorf("expected no upstream call with a spent budget, got %d attempts", p.calls)
+	}
+	if snap.Status != StatusRateLimited || snap.ErrorCode != ErrorCodeBudgetSpent || snap.Timestamp.IsZero() {
+		t.Errorf("expected a budget snapshot, got %+v", snap)
+	}
+
+	now = now.Add(time.Hour + time.Second)
+	if snap = fetch(); p.calls != 4 || snap.Status != StatusOK {
+		t.Errorf("expected fetching to resume after an hour, got %d attempts (%s)", p.calls, snap.Status)
+	}
+}
+
+type mockCostProvider struct {
+	mockProvider
+	failCosts    bool
+	partialCosts bool
+}
+
+func (m *mockCostProvider) FetchCosts(ctx context.Context, auth AuthConfig, period TimePeriod) (*CostBreakdown, error) {
+	if m.failCosts {
+		return nil, errors.New("failed to fetch costs")
+	}
+	cost := &CostBreakdown{
+		Total:    12.5,
+		Currency: "USD",
+		ByModel:  map[string]float64{"model-a": 10, "model-b": 2.5},
+		Period:   period,
+	}
+	if m.partialCosts {
+		cost.ByModel = nil
+		return cost, errors.New("partial data: by model failed")
+	}
+	return cost, nil
+}
+
+func TestRegistry_FetchCosts(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&mockCostProvider{mockProvider: mockProvider{id: "cost-provider", displayName: "Cost"}})
+	r.Register(&mockCostProvider{mockProvider: mockProvider{id: "cost-fail"}, failCosts: true})
+	r.Register(&mockCostProvider{mockProvider: mockProvider{id: "cost-partial"}, partialCosts: true})
+	r.Register(&mockProvider{id: "usage-only"})
+
+	entries := []SubscriptionEntry{
+		{Provider: "cost-provider", Name: "with-costs"},
+		{Provider: "usage-only", Name: "no-costs"},
+		{Provider: "cost-fail", Name: "broken-costs"},
+		{Provider: "not-registered", Name: "missing"},
+		{Provider: "cost-partial", Name: "partial-costs"},
+	}
+
+	period := TimePeriod{
+		Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var results []CostResult
+	captureStderr(t, func() {
+		results = r.FetchCosts(ctx, entries, period)
+	})
+
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+
+	if results[0].Status != StatusOK || results[0].Cost == nil {
+		t.Fatalf("expected cost result for cost-provider, got %+v", results[0])
+	}
+	if results[0].Cost.Total != 12.5 {
+		t.Errorf("expected total 12.5, got %v", results[0].Cost.Total)
+	}
+	if !results[0].Cost.Period.Start.Equal(period.Start) {
+		t.Errorf("expected period to be passed through, got %v", results[0].Cost.Period)
+	}
+
+	if results[1].Status != StatusUnsupported {
+		t.Errorf("expected unsupported for usage-only provider, got %s", results[1].Status)
+	}
+	if results[1].Name != "no-costs" {
+		t.Errorf("expected name 'no-costs', got %q", results[1].Name)
+	}
+
+	if results[2].Status != StatusError || results[2].Error == "" {
+		t.Errorf("expected error for cost-fail, got %+v", results[2])
+	}
+
+	if results[3].Status != StatusError {
+		t.Errorf("expected error for not-registered provider, got %s", results[3].Status)
+	}
+
+	if results[4].Status != StatusError || results[4].Error == "" || results[4].Cost == nil || results[4].Cost.Total != 12.5 {
+		t.Errorf("expected partial costs with an error, got %+v", results[4])
+	}
+}
+
+type flakyCostProvider struct {
+	mockProvider
+	calls int
+	errs  []error
+}
+
+func (f *flakyCostProvider) FetchCosts(ctx context.Context, auth AuthConfig, period TimePeriod) (*CostBreakdown, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return &CostBreakdown{Total: 1, Currency: "USD", Period: period}, nil
+}
+
+func TestRegistry_FetchCosts_RetryAndBreaker(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+	r.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	r.SetBreakerPolicy(BreakerPolicy{FailureThreshold: 1, Cooldown: 10 * time.Minute})
+
+	p := &flakyCostProvider{
+		mockProvider: mockProvider{id: "costs"},
+		errs:         []error{&HTTPError{StatusCode: 502}, &HTTPError{StatusCode: 401}},
+	}
+	r.Register(p)
+	entries := []SubscriptionEntry{{Provider: "costs", Name: "acct"}}
+
+	fetch := func() CostResult {
+		var results []CostResult
+		captureStderr(t, func() {
+			results = r.FetchCosts(context.Background(), entries, TimePeriod{})
+		})
+		return results[0]
+	}
+
+	result := fetch()
+	if p.calls != 2 {
+		t.Errorf("expected the 502 to be retried and the 401 not, got %d attempts", p.calls)
+	}
+	if result.ErrorCode != ErrorCodeUnauthorized {
+		t.Errorf("expected unauthorized, got %s (%s)", result.ErrorCode, result.Error)
+	}
+
+	result = fetch()
+	if p.calls != 2 {
+		t.Errorf("expected the open breaker to skip the upstream call, got %d attempts", p.calls)
+	}
+	if result.Status != StatusUnauthorized || !strings.Contains(result.Error, "circuit open") {
+		t.Errorf("expected a circuit open result, got %s (%s)", result.Status, result.Error)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if result = fetch(); result.Status != StatusOK || p.calls != 3 {
+		t.Errorf("expected a successful probe after the cool-down, got %s after %d attempts", result.Status, p.calls)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	p, err := ParsePeriod("2026-03-01", "2026-03-10", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !p.Start.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected start: %v", p.Start)
+	}
+	if !p.End.Equal(time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected date-only end to be inclusive, got %v", p.End)
+	}
+
+	p, err = ParsePeriod("", "", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !p.End.Equal(now) || !p.Start.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("unexpected default period: %v - %v", p.Start, p.End)
+	}
+
+	if _, err := ParsePeriod("2026-03-10", "2026-03-01", now); err == nil {
+		t.Error("expected error for inverted period")
+	}
+	if _, err := ParsePeriod("yesterday", "", now); err == nil {
+		t.Error("expected error for malformed start")
+	}
+}
+
+type slowProvider struct {
+	mockProvider
+	delay time.Duration
+}
+
+func (s *slowProvider) FetchUsage(ctx context.Context, auth AuthConfig) (*UsageSnapshot, error) {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &UsageSnapshot{ProviderID: s.id, Status: StatusOK}, nil
+}
+
+func TestRegistry_FetchStream_DeliversInCompletionOrder(t *testing.T) {
+	r := NewRegistry()
+	r.Register(&slowProvider{mockProvider: mockProvider{id: "slow"}, delay: 200 * time.Millisecond})
+	r.Register(&slowProvider{mockProvider: mockProvider{id: "fast"}, delay: time.Millisecond})
+
+	entries := []SubscriptionEntry{
+		{Provider: "slow", Name: "slow-sub"},
+		{Provider: "fast", Name: "fast-sub"},
+	}
+
+	var results []Result
+	for res := range r.FetchStream(context.Background(), entries) {
+		results = append(results, res)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Index != 1 || results[0].Snapshot.Name != "fast-sub" {
+		t.Errorf("expected fast provider first, got index %d (%s)", results[0].Index, results[0].Snapshot.Name)
+	}
+	if results[1].Index != 0 || results[1].Snapshot.Name != "slow-sub" {
+		t.Errorf("expected slow provider last, got index %d (%s)", results[1].Index, results[1].Snapshot.Name)
+	}
+}
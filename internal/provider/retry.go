@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how often a failed fetch is retried. Only transient
+// failures (rate limits, upstream errors and timeouts) are retried.
+type RetryPolicy struct {
+	MaxAttempts int           `yaml:"max_attempts" mapstructure:"max_attempts" json:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay" mapstructure:"base_delay" json:"base_delay"`
+	MaxDelay    time.Duration `yaml:"max_delay" mapstructure:"max_delay" json:"max_delay"`
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+	}
+}
+
+// Retryable reports whether a failure of the given class is worth retrying.
+func (c ErrorCode) Retryable() bool {
+	switch c {
+	case ErrorCodeRateLimited, ErrorCodeUnavailable, ErrorCodeTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Delay returns the wait before the given retry (1-based) using exponential
+// backoff with equal jitter. A Retry-After hint from the upstream wins when it
+// is longer, but is still capped at MaxDelay.
+func (p RetryPolicy) Delay(retry int, err error) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d > 0 {
+		half := d / 2
+		d = half + time.Duration(rand.Int64N(int64(half)+1))
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > d {
+		d = httpErr.RetryAfter
+		if p.MaxDelay > 0 && d > p.MaxDelay {
+			d = p.MaxDelay
+		}
+	}
+	return d
+}
+
+// withRetry calls fetch, retrying transient failures. charge, when set, is
+// called before every attempt and stops fetching once it reports false. The
+// value of the last attempt is returned along with its error, so that
+// partial data survives.
+func withRetry[T any](ctx context.Context, policy RetryPolicy, charge func() bool, fetch func() (T, error)) (T, error) {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var last T
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if charge != nil && !charge() {
+			if attempt == 1 {
+				return last, ErrBudgetSpent
+			}
+			break
+		}
+
+		v, err := fetch()
+		if err == nil {
+			return v, nil
+		}
+		last, lastErr = v, err
+
+		if attempt == attempts || !ErrorCodeOf(err).Retryable() {
+			break
+		}
+
+		wait := policy.Delay(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			break
+		}
+		if sleepCtx(ctx, wait) != nil {
+			break
+		}
+	}
+	return last, lastErr
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
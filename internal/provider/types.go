@@ -1,6 +1,9 @@
 package provider
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type AuthType string
 
@@ -22,6 +25,9 @@ const (
 	StatusOK           Status = "ok"
 	StatusError        Status = "error"
 	StatusUnauthorized Status = "unauthorized"
+	StatusRateLimited  Status = "rate_limited"
+	StatusUnavailable  Status = "unavailable"
+	StatusUnsupported  Status = "unsupported"
 )
 
 type UsageSnapshot struct {
@@ -29,23 +35,63 @@ type UsageSnapshot struct {
 	DisplayName string         `json:"display_name"`
 	Name        string         `json:"name"`
 	Timestamp   time.Time      `json:"timestamp"`
+	FetchedAt   *time.Time     `json:"fetched_at,omitempty"`
 	Plan        *PlanInfo      `json:"plan,omitempty"`
 	Metrics     []UsageMetric  `json:"metrics"`
 	Cost        *CostBreakdown `json:"cost,omitempty"`
 	Status      Status         `json:"status"`
 	Error       string         `json:"error,omitempty"`
+	ErrorCode   ErrorCode      `json:"error_code,omitempty"`
+	Breaker     *BreakerState  `json:"breaker,omitempty"`
+	// Stale is set when Metrics, Plan and Cost come from the last successful
+	// fetch at LastSuccessAt because the most recent one failed.
+	Stale         bool       `json:"stale,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
 }
 
 type UsageMetric struct {
 	Name   string      `json:"name"`
 	Window UsageWindow `json:"window"`
 	Amount UsageAmount `json:"amount"`
+	// PaceDelta is the used share minus the elapsed share of the window in
+	// percentage points when the snapshot was taken; positive means usage is
+	// ahead of an even pace.
+	PaceDelta *float64  `json:"pace_delta,omitempty"`
+	Forecast  *Forecast `json:"forecast,omitempty"`
 }
 
+// UsageWindow is the period a metric is counted over. StartsAt and Duration
+// are set when the provider reports them; Duration is the nominal length of
+// the window, e.g. 5h for a rolling five hour quota, and is encoded as
+// duration_seconds.
 type UsageWindow struct {
-	ID       string     `json:"id"`
-	Label    string     `json:"label"`
-	ResetsAt *time.Time `json:"resets_at,omitempty"`
+	ID       string        `json:"id"`
+	Label    string        `json:"label"`
+	StartsAt *time.Time    `json:"starts_at,omitempty"`
+	ResetsAt *time.Time    `json:"resets_at,omitempty"`
+	Duration time.Duration `json:"-"`
+}
+
+// window has UsageWindow's fields without its JSON methods.
+type window UsageWindow
+
+type usageWindowJSON struct {
+	window
+	DurationSeconds int64 `json:"duration_seconds,omitempty"`
+}
+
+func (w UsageWindow) MarshalJSON() ([]byte, error) {
+	return json.Marshal(usageWindowJSON{window: window(w), DurationSeconds: int64(w.Duration / time.Second)})
+}
+
+func (w *UsageWindow) UnmarshalJSON(data []byte) error {
+	var v usageWindowJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*w = UsageWindow(v.window)
+	w.Duration = time.Duration(v.DurationSeconds) * time.Second
+	return nil
 }
 
 type UsageAmount struct {
@@ -63,12 +109,26 @@ type PlanInfo struct {
 
 type CostBreakdown struct {
 	Total    float64            `json:"total"`
+	Input    float64            `json:"input,omitempty"`
+	Output   float64            `json:"output,omitempty"`
+	Other    float64            `json:"other,omitempty"`
 	Currency string             `json:"currency"`
 	ByModel  map[string]float64 `json:"by_model,omitempty"`
 	ByDay    []DailyCost        `json:"by_day,omitempty"`
 	Period   TimePeriod         `json:"period"`
 }
 
+// CostResult is the outcome of a cost query for a single subscription
+type CostResult struct {
+	ProviderID  string         `json:"provider_id"`
+	DisplayName string         `json:"display_name"`
+	Name        string         `json:"name"`
+	Cost        *CostBreakdown `json:"cost,omitempty"`
+	Status      Status         `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	ErrorCode   ErrorCode      `json:"error_code,omitempty"`
+}
+
 type DailyCost struct {
 	Date string  `json:"date"`
 	Cost float64 `json:"cost"`
@@ -91,6 +151,8 @@ type SubscriptionEntry struct {
 	Provider string     `yaml:"provider" json:"provider"`
 	Name     string     `yaml:"name" json:"name"`
 	Auth     AuthConfig `yaml:"auth" json:"auth"`
+	// RefreshInterval overrides settings.refresh_interval for this subscription
+	RefreshInterval time.Duration `yaml:"refresh_interval,omitempty" mapstructure:"refresh_interval" json:"refresh_interval,omitempty"`
 }
 
 // Ptr is a helper to create pointer to a value
@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"fmt"
+	"time"
+)
+
+// Start returns when the window started: StartsAt, or ResetsAt minus Duration
+// when the provider only reports those.
+func (w UsageWindow) Start() *time.Time {
+	if w.StartsAt != nil {
+		return w.StartsAt
+	}
+	if w.ResetsAt != nil && w.Duration > 0 {
+		return Ptr(w.ResetsAt.Add(-w.Duration))
+	}
+	return nil
+}
+
+// Elapsed returns the fraction of the window that has passed at now, clamped
+// to [0, 1]. ok is false when the window's bounds are unknown.
+func (w UsageWindow) Elapsed(now time.Time) (fraction float64, ok bool) {
+	start := w.Start()
+	if start == nil || w.ResetsAt == nil || !w.ResetsAt.After(*start) {
+		return 0, false
+	}
+
+	fraction = float64(now.Sub(*start)) / float64(w.ResetsAt.Sub(*start))
+	return min(max(fraction, 0), 1), true
+}
+
+// Pace compares the used share of m's limit with the elapsed share of
+// its window at t, in percentage points. ok is false when m has no limit or
+// its window bounds are unknown.
+func (m UsageMetric) Pace(t time.Time) (delta float64, ok bool) {
+	if m.Amount.Used == nil || m.Amount.Limit == nil || *m.Amount.Limit <= 0 {
+		return 0, false
+	}
+	elapsed, ok := m.Window.Elapsed(t)
+	if !ok {
+		return 0, false
+	}
+	return (*m.Amount.Used / *m.Amount.Limit - elapsed) * 100, true
+}
+
+// ApplyPace sets PaceDelta on the metrics of snap as of its timestamp.
+func ApplyPace(snap *UsageSnapshot) {
+	t := snap.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+	for i := range snap.Metrics {
+		if delta, ok := snap.Metrics[i].Pace(t); ok {
+			snap.Metrics[i].PaceDelta = Ptr(delta)
+		}
+	}
+}
+
+// WindowLabel formats a window duration in the largest whole unit, e.g. 5h
+// for 300 minutes or 7d for a week.
+func WindowLabel(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return ""
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
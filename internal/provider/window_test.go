@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUsageWindow_Elapsed(t *testing.T) {
+	start := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	resets := start.Add(5 * time.Hour)
+
+	explicit := UsageWindow{StartsAt: &start, ResetsAt: &resets}
+	if f, ok := explicit.Elapsed(start.Add(2 * time.Hour)); !ok || f != 0.4 {
+		t.Errorf("expected 40%% elapsed, got %v, %v", f, ok)
+	}
+
+	derived := UsageWindow{ResetsAt: &resets, Duration: 5 * time.Hour}
+	if s := derived.Start(); s == nil || !s.Equal(start) {
+		t.Errorf("expected start derived from duration, got %v", s)
+	}
+	if f, ok := derived.Elapsed(resets.Add(time.Hour)); !ok || f != 1 {
+		t.Errorf("expected elapsed clamped to 1, got %v, %v", f, ok)
+	}
+
+	if _, ok := (UsageWindow{ResetsAt: &resets}).Elapsed(start); ok {
+		t.Error("expected unknown elapsed without start or duration")
+	}
+}
+
+func TestWindowLabel(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                  "",
+		300 * time.Minute:  "5h",
+		7 * 24 * time.Hour: "7d",
+		90 * time.Minute:   "90m",
+		24 * time.Hour:     "1d",
+		45 * time.Second:   "45s",
+	}
+	for d, want := range tests {
+		if got := WindowLabel(d); got != want {
+			t.Errorf("WindowLabel(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestApplyPace(t *testing.T) {
+	start := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+	resets := start.Add(10 * time.Hour)
+
+	snap := &UsageSnapshot{
+		Timestamp: start.Add(4 * time.Hour),
+		Metrics: []UsageMetric{
+			{
+				Name:   "paced",
+				Window: UsageWindow{StartsAt: &start, ResetsAt: &resets},
+				Amount: UsageAmount{Used: Ptr(62.0), Limit: Ptr(100.0)},
+			},
+			{
+				Name:   "no window",
+				Amount: UsageAmount{Used: Ptr(62.0), Limit: Ptr(100.0)},
+			},
+		},
+	}
+	ApplyPace(snap)
+
+	if d := snap.Metrics[0].PaceDelta; d == nil || *d < 21.99 || *d > 22.01 {
+		t.Errorf("expected pace delta +22, got %v", d)
+	}
+	if snap.Metrics[1].PaceDelta != nil {
+		t.Errorf("expected no pace delta without window bounds, got %v", *snap.Metrics[1].PaceDelta)
+	}
+}
+
+func TestUsageWindow_JSON(t *testing.T) {
+	resets := time.Date(2026, 2, 15, 15, 0, 0, 0, time.UTC)
+	w := UsageWindow{ID: "window", Label: "5h", ResetsAt: &resets, Duration: 5 * time.Hour}
+
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"duration_seconds":18000`) || strings.Contains(string(data), `"Duration"`) {
+		t.Errorf("expected the duration in seconds, got %s", data)
+	}
+
+	var got UsageWindow
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Duration != w.Duration || got.Label != w.Label || !got.ResetsAt.Equal(resets) {
+		t.Errorf("round trip changed the window: %+v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+// SnapshotsFile is the name of the last-known snapshot file in the data directory
+const SnapshotsFile = "snapshots.json"
+
+// SnapshotLogDir is the name of the JSON-lines log directory written by
+// collect, which holds one file per UTC day.
+const SnapshotLogDir = "snapshot-log"
+
+const snapshotLogDateLayout = "2006-01-02"
+
+// Entry is the persisted state of a single subscription: the snapshot that is
+// currently served and the last successful one used as a fallback.
+type Entry struct {
+	Snapshot provider.UsageSnapshot  `json:"snapshot"`
+	LastGood *provider.UsageSnapshot `json:"last_good,omitempty"`
+}
+
+// LoadSnapshots reads the entries saved by SaveSnapshots. A missing file is
+// not an error and yields no entries.
+func LoadSnapshots(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// SaveSnapshots atomically replaces the snapshot file at path.
+func SaveSnapshots(path string, entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data, 0640)
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// Update merges freshly fetched snapshots into entries the same way the serve
+// cache does: successful snapshots become the new last-good, failed ones fall
+// back to it marked stale. Entries of other subscriptions are kept.
+func Update(entries []Entry, snaps []provider.UsageSnapshot, now time.Time) []Entry {
+	index := make(map[string]int, len(entries))
+	for i, e := range entries {
+		index[e.Snapshot.Name] = i
+	}
+
+	for _, snap := range snaps {
+		snap.FetchedAt = provider.Ptr(now)
+
+		i, ok := index[snap.Name]
+		if !ok {
+			i = len(entries)
+			index[snap.Name] = i
+			entries = append(entries, Entry{})
+		}
+
+		if snap.Status == provider.StatusOK {
+			good := snap
+			entries[i].LastGood = &good
+		}
+		entries[i].Snapshot = provider.WithLastKnownGood(snap, entries[i].LastGood)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Snapshot.Name < entries[j].Snapshot.Name
+	})
+	return entries
+}
+
+// WithLastGood falls back to the last-known-good values of snap's entry when
+// snap is a failed fetch, see provider.WithLastKnownGood.
+func WithLastGood(entries []Entry, snap provider.UsageSnapshot) provider.UsageSnapshot {
+	for _, e := range entries {
+		if e.Snapshot.Name == snap.Name {
+			return provider.WithLastKnownGood(snap, e.LastGood)
+		}
+	}
+	return snap
+}
+
+// AppendSnapshotLog appends snaps to the file of now's day in the log
+// directory dir, one snapshot per line.
+func AppendSnapshotLog(dir string, snaps []provider.UsageSnapshot, now time.Time) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, snap := range snaps {
+		if err := enc.Encode(snap); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(dir, now.UTC().Format(snapshotLogDateLayout)+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// PruneSnapshotLog removes the day files of the log directory dir that end
+// before cutoff. A missing directory is not an error.
+func PruneSnapshotLog(dir string, cutoff time.Time) error {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		day, err := time.Parse(snapshotLogDateLayout, strings.TrimSuffix(name, ".jsonl"))
+		if err != nil {
+			continue
+		}
+		if day.Add(24 * time.Hour).After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
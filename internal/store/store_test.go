@@ -0,0 +1,138 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/subscriptions-monitor/internal/provider"
+)
+
+func TestSnapshots_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", SnapshotsFile)
+
+	entries, err := LoadSnapshots(path)
+	if err != nil || entries != nil {
+		t.Fatalf("expected no entries for missing file, got %v, %v", entries, err)
+	}
+
+	fetchedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	good := provider.UsageSnapshot{Name: "sub", Status: provider.StatusOK, FetchedAt: &fetchedAt}
+	want := []Entry{
+		{Snapshot: good, LastGood: &good},
+	}
+
+	if err := SaveSnapshots(path, want); err != nil {
+		t.Fatalf("SaveSnapshots failed: %v", err)
+	}
+
+	got, err := LoadSnapshots(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshots failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Snapshot.Name != "sub" || got[0].LastGood == nil {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+	if !got[0].Snapshot.FetchedAt.Equal(fetchedAt) {
+		t.Errorf("expected fetched_at %v, got %v", fetchedAt, got[0].Snapshot.FetchedAt)
+	}
+
+	files, _ := os.ReadDir(filepath.Dir(path))
+	if len(files) != 1 {
+		t.Errorf("expected temporary files to be cleaned up, got %d files", len(files))
+	}
+}
+
+func TestUpdate_FallsBackToLastGood(t *testing.T) {
+	used := 5.0
+	good := provider.UsageSnapshot{
+		Name:    "sub-a",
+		Status:  provider.StatusOK,
+		Metrics: []provider.UsageMetric{{Name: "m", Amount: provider.UsageAmount{Used: &used}}},
+	}
+	other := provider.UsageSnapshot{Name: "sub-b", Status: provider.StatusOK}
+
+	t1 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := Update(nil, []provider.UsageSnapshot{good, other}, t1)
+	if len(entries) != 2 || entries[0].LastGood == nil {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	failed := provider.UsageSnapshot{Name: "sub-a", Status: provider.StatusError, Error: "boom"}
+	t2 := t1.Add(time.Minute)
+	entries = Update(entries, []provider.UsageSnapshot{failed}, t2)
+	if len(entries) != 2 {
+		t.Fatalf("expected other subscriptions to be kept, got %+v", entries)
+	}
+
+	got := entries[0].Snapshot
+	if !got.Stale || got.Status != provider.StatusError || len(got.Metrics) != 1 {
+		t.Errorf("expected stale fallback to last good, got %+v", got)
+	}
+	if got.LastSuccessAt == nil || !got.LastSuccessAt.Equal(t1) {
+		t.Errorf("expected last success at %v, got %v", t1, got.LastSuccessAt)
+	}
+	if got.FetchedAt == nil || !got.FetchedAt.Equal(t2) {
+		t.Errorf("expected fetched_at %v, got %v", t2, got.FetchedAt)
+	}
+}
+
+func TestSnapshotLog(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), SnapshotLogDir)
+	day1 := time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+
+	for _, now := range []time.Time{day1, day1, day2} {
+		snaps := []provider.UsageSnapshot{{Name: "sub-a"}, {Name: "sub-b"}}
+		if err := AppendSnapshotLog(dir, snaps, now); err != nil {
+			t.Fatalf("AppendSnapshotLog failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "2026-01-01.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 4 {
+		t.Errorf("expected 4 lines, got %d", lines)
+	}
+
+	if err := PruneSnapshotLog(dir, day2); err != nil {
+		t.Fatalf("PruneSnapshotLog failed: %v", err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "2026-01-02.jsonl" {
+		t.Errorf("expected only the second day to be kept, got %v", files)
+	}
+
+	if err := PruneSnapshotLog(filepath.Join(t.TempDir(), "missing"), day2); err != nil {
+		t.Errorf("expected a missing directory to be ignored, got %v", err)
+	}
+}
+
+func TestWithLastGood(t *testing.T) {
+	fetchedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	good := provider.UsageSnapshot{
+		Name:      "sub-a",
+		Status:    provider.StatusOK,
+		FetchedAt: &fetchedAt,
+		Metrics:   []provider.UsageMetric{{Name: "m"}},
+	}
+	entries := []Entry{{Snapshot: good, LastGood: &good}}
+
+	failed := provider.UsageSnapshot{Name: "sub-a", Status: provider.StatusUnavailable, Error: "boom", Metrics: []provider.UsageMetric{}}
+	got := WithLastGood(entries, failed)
+	if !got.Stale || len(got.Metrics) != 1 || got.Error != "boom" {
+		t.Errorf("expected the last good metrics marked stale, got %+v", got)
+	}
+	if got.LastSuccessAt == nil || !got.LastSuccessAt.Equal(fetchedAt) {
+		t.Errorf("expected last success at %v, got %v", fetchedAt, got.LastSuccessAt)
+	}
+
+	other := provider.UsageSnapshot{Name: "sub-b", Status: provider.StatusUnavailable}
+	if got := WithLastGood(entries, other); got.Stale {
+		t.Errorf("expected a subscription without entry to be left alone, got %+v", got)
+	}
+}